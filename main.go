@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"network-monitor/server" // Remplacez par le nom de votre module
@@ -15,12 +16,56 @@ func main() {
 	}
 	defer srv.Close()
 
+	// Secret de l'API d'administration (émission/révocation des jetons de sonde)
+	srv.SetAdminToken(os.Getenv("MONITOR_ADMIN_TOKEN"))
+
+	// Certificats clients obligatoires pour les sondes (mTLS)
+	srv.SetRequireClientCert(os.Getenv("MONITOR_REQUIRE_CLIENT_CERT") == "1")
+
+	// Compte administrateur initial si aucun utilisateur n'existe
+	if err := srv.EnsureAdminUser(os.Getenv("MONITOR_ADMIN_USER"), os.Getenv("MONITOR_ADMIN_PASSWORD")); err != nil {
+		log.Fatalf("Échec de la création de l'administrateur initial: %v", err)
+	}
+
 	// Créer un mux personnalisé
 	mux := http.NewServeMux()
 	mux.HandleFunc("/data", srv.HandleMonitoringData)
-	mux.HandleFunc("/api/dashboard_data", srv.HandleAPIDashboardData)
-	mux.HandleFunc("/", srv.HandleDashboard)
-	mux.HandleFunc("/api/clients", srv.HandleGetClients)
+	mux.HandleFunc("/api/probe/check", srv.HandleProbeCheck)
+	mux.HandleFunc("/heartbeat", srv.HandleHeartbeat)
+	mux.HandleFunc("/login", srv.HandleLogin)
+	mux.HandleFunc("/logout", srv.HandleLogout)
+	mux.HandleFunc("/api/dashboard_data", srv.RequireRole(server.RoleViewer, srv.HandleAPIDashboardData))
+	mux.HandleFunc("/", srv.RequireRole(server.RoleViewer, srv.HandleDashboard))
+	mux.HandleFunc("/api/clients", srv.RequireRole(server.RoleViewer, srv.HandleGetClients))
+	mux.HandleFunc("/api/clients/delete", srv.RequireRole(server.RoleOperator, srv.HandleDeleteClient))
+	mux.HandleFunc("/api/checks", srv.RequireRole(server.RoleViewer, srv.HandleChecks))
+	mux.HandleFunc("/api/path_traces", srv.RequireRole(server.RoleViewer, srv.HandlePathTraces))
+	mux.HandleFunc("/api/gaps", srv.RequireRole(server.RoleViewer, srv.HandleGaps))
+	mux.HandleFunc("/ws", srv.RequireRole(server.RoleViewer, srv.HandleWebSocket))
+	mux.HandleFunc("/certificates", srv.RequireRole(server.RoleViewer, srv.HandleCertificatesPage))
+	mux.HandleFunc("/api/certificates", srv.RequireRole(server.RoleViewer, srv.HandleGetCertificates))
+	mux.HandleFunc("/api/alerts", srv.RequireRole(server.RoleViewer, srv.HandleAlerts))
+	mux.HandleFunc("/reports", srv.RequireRole(server.RoleViewer, srv.HandleReportsPage))
+	mux.HandleFunc("/api/reports/uptime", srv.RequireRole(server.RoleViewer, srv.HandleUptimeReport))
+	mux.HandleFunc("/api/maintenance", srv.RequireRole(server.RoleViewer, srv.HandleMaintenance))
+	mux.HandleFunc("/api/silences", srv.RequireRole(server.RoleViewer, srv.HandleSilences))
+	mux.HandleFunc("/slos", srv.RequireRole(server.RoleViewer, srv.HandleSLOPage))
+	mux.HandleFunc("/api/slos", srv.RequireRole(server.RoleViewer, srv.HandleSLOs))
+	mux.HandleFunc("/api/baseline", srv.RequireRole(server.RoleViewer, srv.HandleBaseline))
+	mux.HandleFunc("/api/phase_shifts", srv.RequireRole(server.RoleViewer, srv.HandlePhaseShifts))
+	mux.HandleFunc("/incidents", srv.RequireRole(server.RoleViewer, srv.HandleIncidentsPage))
+	mux.HandleFunc("/api/incidents", srv.RequireRole(server.RoleViewer, srv.HandleIncidents))
+	mux.HandleFunc("/matrix", srv.RequireRole(server.RoleViewer, srv.HandleMatrixPage))
+	mux.HandleFunc("/api/matrix", srv.RequireRole(server.RoleViewer, srv.HandleMatrix))
+	mux.HandleFunc("/sites", srv.RequireRole(server.RoleViewer, srv.HandleSitesPage))
+	mux.HandleFunc("/api/sites", srv.RequireRole(server.RoleViewer, srv.HandleSites))
+	mux.HandleFunc("/api/remote_ips", srv.RequireRole(server.RoleViewer, srv.HandleRemoteIPs))
+	mux.HandleFunc("/api/protocols", srv.RequireRole(server.RoleViewer, srv.HandleProtocols))
+	mux.HandleFunc("/api/admin/tokens", srv.RequireRole(server.RoleAdmin, srv.HandleAdminTokens))
+	mux.HandleFunc("/api/admin/users", srv.RequireRole(server.RoleAdmin, srv.HandleAdminUsers))
+	mux.HandleFunc("/api/admin/audit", srv.RequireRole(server.RoleAdmin, srv.HandleAdminAudit))
+	mux.HandleFunc("/api/admin/tenants", srv.RequireRole(server.RoleAdmin, srv.HandleAdminTenants))
+	mux.HandleFunc("/api/admin/alert_rules", srv.RequireRole(server.RoleAdmin, srv.HandleAdminAlertRules))
 
 	// Serveur HTTP avec timeouts configurés
 	httpServer := &http.Server{
@@ -32,6 +77,20 @@ func main() {
 		MaxHeaderBytes: 1 << 20,           // 1 MB
 	}
 
+	// TLS optionnel, avec vérification des certificats clients si une AC est fournie
+	certFile := os.Getenv("MONITOR_TLS_CERT")
+	keyFile := os.Getenv("MONITOR_TLS_KEY")
+	if certFile != "" {
+		tlsConfig, err := server.NewTLSConfig(certFile, keyFile, os.Getenv("MONITOR_TLS_CLIENT_CA"))
+		if err != nil {
+			log.Fatalf("Échec de la configuration TLS: %v", err)
+		}
+		httpServer.TLSConfig = tlsConfig
+
+		log.Println("Serveur démarré sur :8080 (TLS)")
+		log.Fatal(httpServer.ListenAndServeTLS("", ""))
+	}
+
 	log.Println("Serveur démarré sur :8080")
 	log.Fatal(httpServer.ListenAndServe())
 }
\ No newline at end of file
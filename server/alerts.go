@@ -0,0 +1,482 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// alertEvaluationInterval is how often the alert rules are evaluated.
+const alertEvaluationInterval = time.Minute
+
+// Alert rule types.
+const (
+	AlertTypeCertExpiry       = "cert_expiry"        // params: days (14)
+	AlertTypeCertChainChanged = "cert_chain_changed" // params: hold_hours (24), include_renewals (0)
+	AlertTypeClientStale      = "client_stale"       // params: multiple (3)
+	AlertTypeSLOBurnRate      = "slo_burn_rate"      // params: slo_id (0 = all), threshold (14.4), long_minutes (60), short_minutes (5)
+	AlertTypePhaseShift       = "phase_shift"        // params: min_ratio (2), hold_hours (24)
+	AlertTypeRemoteIPChanged  = "remote_ip_changed"  // params: hold_hours (24), include_expected (0)
+)
+
+// Alert states.
+const (
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// alertFinding is a condition found by an evaluator. An alert fires for each
+// (client, target) found and resolves once it is no longer found.
+type alertFinding struct {
+	ClientID string
+	Target   string
+	Message  string
+}
+
+// alertEvaluators maps each rule type to the function finding its conditions.
+var alertEvaluators = map[string]func(s *Server, rule AlertRule) ([]alertFinding, error){
+	AlertTypeCertExpiry:       (*Server).evaluateCertExpiry,
+	AlertTypeCertChainChanged: (*Server).evaluateCertChainChanged,
+	AlertTypeClientStale:      (*Server).evaluateClientStale,
+	AlertTypeSLOBurnRate:      (*Server).evaluateSLOBurnRate,
+	AlertTypePhaseShift:       (*Server).evaluatePhaseShift,
+	AlertTypeRemoteIPChanged:  (*Server).evaluateRemoteIPChanged,
+}
+
+var errUnknownAlertType = errors.New("type de règle d'alerte inconnu")
+
+// param returns a rule parameter, or def when it is not set.
+func (rule AlertRule) param(name string, def float64) float64 {
+	if v, ok := rule.Params[name]; ok {
+		return v
+	}
+	return def
+}
+
+// evaluateCertExpiry finds the targets whose leaf certificate expires within
+// the "days" parameter, or has already expired.
+func (s *Server) evaluateCertExpiry(rule AlertRule) ([]alertFinding, error) {
+	days := rule.param("days", 14)
+	certs, err := s.getTargetCertificates(rule.TenantID, rule.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []alertFinding
+	limit := time.Now().Add(time.Duration(days * float64(24*time.Hour)))
+	for _, c := range certs {
+		if c.NotAfter.After(limit) {
+			continue
+		}
+		msg := fmt.Sprintf("Le certificat de %s expire le %s (%d jours)", c.TargetURL, c.NotAfter.Format("02/01/2006"), c.DaysRemaining)
+		if c.DaysRemaining < 0 {
+			msg = fmt.Sprintf("Le certificat de %s a expiré le %s", c.TargetURL, c.NotAfter.Format("02/01/2006"))
+		}
+		findings = append(findings, alertFinding{ClientID: c.ClientID, Target: c.TargetURL, Message: msg})
+	}
+	return findings, nil
+}
+
+// evaluateCertChainChanged finds the targets whose certificate chain changed
+// unexpectedly (or at all with include_renewals) within the last hold_hours.
+func (s *Server) evaluateCertChainChanged(rule AlertRule) ([]alertFinding, error) {
+	hold := time.Duration(rule.param("hold_hours", 24) * float64(time.Hour))
+	includeRenewals := rule.param("include_renewals", 0) != 0
+	certs, err := s.getTargetCertificates(rule.TenantID, rule.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []alertFinding
+	for _, c := range certs {
+		if c.ChangedAt == nil || time.Since(*c.ChangedAt) > hold {
+			continue
+		}
+		if !c.UnexpectedChange && !includeRenewals {
+			continue
+		}
+		msg := fmt.Sprintf("La chaîne de certificats de %s a changé le %s", c.TargetURL, c.ChangedAt.Format("02/01/2006 15:04"))
+		if len(c.Chain) > 0 {
+			msg += " (émetteur: " + c.Chain[0].Issuer + ")"
+		}
+		findings = append(findings, alertFinding{ClientID: c.ClientID, Target: c.TargetURL, Message: msg})
+	}
+	return findings, nil
+}
+
+// alertRoutine periodically evaluates the alert rules and closes the incidents
+// whose clients stopped reporting.
+func (s *Server) alertRoutine() {
+	ticker := time.NewTicker(alertEvaluationInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.expireIncidents()
+		s.evaluateAlerts()
+	}
+}
+
+// evaluateAlerts evaluates every enabled rule of every tenant.
+func (s *Server) evaluateAlerts() {
+	rules, err := s.listAlertRules("")
+	if err != nil {
+		log.Printf("Erreur de récupération des règles d'alerte: %v", err)
+		return
+	}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if err := s.evaluateAlertRule(rule); err != nil {
+			log.Printf("Erreur d'évaluation de la règle d'alerte %d (%s): %v", rule.ID, rule.Name, err)
+		}
+	}
+}
+
+// evaluateAlertRule fires an alert for each new finding of the rule and
+// resolves the firing alerts whose condition is gone. Findings for clients
+// that are silenced or in maintenance do not fire; alerts already firing for
+// them stay as they are.
+func (s *Server) evaluateAlertRule(rule AlertRule) error {
+	evaluate, ok := alertEvaluators[rule.Type]
+	if !ok {
+		return errUnknownAlertType
+	}
+	findings, err := evaluate(s, rule)
+	if err != nil {
+		return err
+	}
+
+	firing, err := s.firingAlerts(rule.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	suppressed, err := s.alertSuppression(rule.TenantID, now)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(findings))
+	for _, f := range findings {
+		key := f.ClientID + "\x00" + f.Target
+		found[key] = true
+
+		if a, ok := firing[key]; ok {
+			if _, err := s.db.Exec(`UPDATE alerts SET last_seen_at = ?, message = ? WHERE id = ?`, now, f.Message, a.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if suppressed(f.ClientID, rule.ID) {
+			continue
+		}
+
+		res, err := s.db.Exec(`
+			INSERT INTO alerts (tenant_id, rule_id, client_id, target, message, state, started_at, last_seen_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			rule.TenantID, rule.ID, f.ClientID, f.Target, f.Message, AlertStateFiring, now, now)
+		if err != nil {
+			return err
+		}
+		a := Alert{
+			TenantID:   rule.TenantID,
+			RuleID:     rule.ID,
+			RuleName:   rule.Name,
+			Type:       rule.Type,
+			ClientID:   f.ClientID,
+			Target:     f.Target,
+			Message:    f.Message,
+			State:      AlertStateFiring,
+			StartedAt:  now,
+			LastSeenAt: now,
+		}
+		a.ID, _ = res.LastInsertId()
+		log.Printf("🔔 Alerte [%s] %s: %s", rule.Name, f.ClientID, f.Message)
+		s.hub.publishAlert(a)
+	}
+
+	for key, a := range firing {
+		if found[key] {
+			continue
+		}
+		if err := s.resolveAlert(&a, now); err != nil {
+			return err
+		}
+		log.Printf("✅ Alerte résolue [%s] %s: %s", rule.Name, a.ClientID, a.Target)
+		s.hub.publishAlert(a)
+	}
+	return nil
+}
+
+func (s *Server) resolveAlert(a *Alert, at time.Time) error {
+	if _, err := s.db.Exec(`UPDATE alerts SET state = ?, resolved_at = ? WHERE id = ?`, AlertStateResolved, at, a.ID); err != nil {
+		return err
+	}
+	a.State = AlertStateResolved
+	a.ResolvedAt = &at
+	return nil
+}
+
+// firingAlerts returns the firing alerts of a rule keyed by client and target.
+func (s *Server) firingAlerts(ruleID int64) (map[string]Alert, error) {
+	alerts, err := s.queryAlerts(`WHERE a.rule_id = ? AND a.state = ?`, ruleID, AlertStateFiring)
+	if err != nil {
+		return nil, err
+	}
+	firing := make(map[string]Alert, len(alerts))
+	for _, a := range alerts {
+		firing[a.ClientID+"\x00"+a.Target] = a
+	}
+	return firing, nil
+}
+
+// listAlerts returns the most recent alerts of a tenant in the given state
+// ("" for all states).
+func (s *Server) listAlerts(tenantID, state string, limit int) ([]Alert, error) {
+	return s.queryAlerts(`
+		WHERE a.tenant_id = ? AND (? = '' OR a.state = ?)
+		ORDER BY a.started_at DESC
+		LIMIT ?`, tenantID, state, state, limit)
+}
+
+func (s *Server) queryAlerts(where string, args ...interface{}) ([]Alert, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, a.tenant_id, a.rule_id, COALESCE(r.name, ''), COALESCE(r.type, ''), a.client_id, a.target,
+		       a.message, a.state, a.started_at, a.last_seen_at, a.resolved_at
+		FROM alerts a LEFT JOIN alert_rules r ON r.id = a.rule_id
+		`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		var a Alert
+		var message sql.NullString
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.TenantID, &a.RuleID, &a.RuleName, &a.Type, &a.ClientID, &a.Target,
+			&message, &a.State, &a.StartedAt, &a.LastSeenAt, &resolvedAt); err != nil {
+			log.Printf("Erreur de scan des alertes: %v", err)
+			continue
+		}
+		a.Message = message.String
+		if resolvedAt.Valid {
+			a.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+// listAlertRules returns the alert rules of a tenant, or of all tenants when
+// tenantID is empty.
+func (s *Server) listAlertRules(tenantID string) ([]AlertRule, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, name, type, client_id, params, enabled, created_at
+		FROM alert_rules
+		WHERE ? = '' OR tenant_id = ?
+		ORDER BY id`, tenantID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []AlertRule{}
+	for rows.Next() {
+		var rule AlertRule
+		var params sql.NullString
+		if err := rows.Scan(&rule.ID, &rule.TenantID, &rule.Name, &rule.Type, &rule.ClientID, &params, &rule.Enabled, &rule.CreatedAt); err != nil {
+			log.Printf("Erreur de scan des règles d'alerte: %v", err)
+			continue
+		}
+		if params.Valid && params.String != "" {
+			if err := json.Unmarshal([]byte(params.String), &rule.Params); err != nil {
+				log.Printf("Paramètres invalides pour la règle d'alerte %d: %v", rule.ID, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// saveAlertRule creates the rule when its ID is zero and updates it otherwise.
+// Disabling a rule resolves its firing alerts.
+func (s *Server) saveAlertRule(rule *AlertRule) error {
+	if _, ok := alertEvaluators[rule.Type]; !ok {
+		return errUnknownAlertType
+	}
+	params, _ := json.Marshal(rule.Params)
+
+	if rule.ID == 0 {
+		rule.CreatedAt = time.Now()
+		res, err := s.db.Exec(`
+			INSERT INTO alert_rules (tenant_id, name, type, client_id, params, enabled, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			rule.TenantID, rule.Name, rule.Type, rule.ClientID, string(params), rule.Enabled, rule.CreatedAt)
+		if err != nil {
+			return err
+		}
+		rule.ID, err = res.LastInsertId()
+		return err
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE alert_rules SET name = ?, type = ?, client_id = ?, params = ?, enabled = ?
+		WHERE id = ? AND tenant_id = ?`,
+		rule.Name, rule.Type, rule.ClientID, string(params), rule.Enabled, rule.ID, rule.TenantID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if !rule.Enabled {
+		_, err = s.db.Exec(`UPDATE alerts SET state = ?, resolved_at = ? WHERE rule_id = ? AND state = ?`,
+			AlertStateResolved, time.Now(), rule.ID, AlertStateFiring)
+	}
+	return err
+}
+
+// deleteAlertRule removes a rule of a tenant (any tenant when tenantID is
+// empty) and its alerts.
+func (s *Server) deleteAlertRule(tenantID string, id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM alert_rules WHERE id = ? AND (? = '' OR tenant_id = ?)`, id, tenantID, tenantID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM alerts WHERE rule_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// HandleAlerts returns the alerts of the tenant (GET ?state=firing|resolved|all&limit=).
+func (s *Server) HandleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "":
+		state = AlertStateFiring
+	case "all":
+		state = ""
+	case AlertStateFiring, AlertStateResolved:
+	default:
+		http.Error(w, "state invalide", http.StatusBadRequest)
+		return
+	}
+	limit := 200
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	alerts, err := s.listAlerts(s.requestTenant(r), state, limit)
+	if err != nil {
+		log.Printf("Erreur de récupération des alertes: %v", err)
+		http.Error(w, "Erreur de récupération des alertes", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+// HandleAdminAlertRules lists (GET), creates (POST), updates (PUT ?id=) and
+// deletes (DELETE ?id=) alert rules.
+func (s *Server) HandleAdminAlertRules(w http.ResponseWriter, r *http.Request) {
+	actor := userFromContext(r.Context())
+	scope := adminTenantScope(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := s.listAlertRules(scope)
+		if err != nil {
+			log.Printf("Erreur de récupération des règles d'alerte: %v", err)
+			http.Error(w, "Erreur de récupération des règles d'alerte", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, rules)
+
+	case http.MethodPost, http.MethodPut:
+		rule := AlertRule{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		rule.ID = 0
+		if r.Method == http.MethodPut {
+			id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+			if err != nil {
+				http.Error(w, "id invalide", http.StatusBadRequest)
+				return
+			}
+			rule.ID = id
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Type
+		}
+		if actor.TenantID != "" || rule.TenantID == "" {
+			rule.TenantID = scope
+		}
+		if rule.TenantID == "" {
+			rule.TenantID = defaultTenantID
+		}
+
+		if err := s.saveAlertRule(&rule); err != nil {
+			switch err {
+			case errUnknownAlertType:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case sql.ErrNoRows:
+				http.Error(w, "Règle introuvable", http.StatusNotFound)
+			default:
+				log.Printf("Erreur d'enregistrement de la règle d'alerte %s: %v", rule.Name, err)
+				http.Error(w, "Erreur d'enregistrement de la règle d'alerte", http.StatusInternalServerError)
+			}
+			return
+		}
+		s.audit(r, rule.TenantID, actor.Username, "alert_rule_save", strconv.FormatInt(rule.ID, 10), "type="+rule.Type+" name="+rule.Name)
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, rule)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		if err := s.deleteAlertRule(scope, id); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Règle introuvable", http.StatusNotFound)
+				return
+			}
+			log.Printf("Erreur de suppression de la règle d'alerte %d: %v", id, err)
+			http.Error(w, "Erreur de suppression de la règle d'alerte", http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, scope, actor.Username, "alert_rule_delete", strconv.FormatInt(id, 10), "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Anomaly detection strategies.
+const (
+	AnomalyStrategyFixed    = "fixed"    // Total latency above a fixed threshold
+	AnomalyStrategyMAD      = "mad"      // Robust z-score against the rolling median
+	AnomalyStrategyEWMA     = "ewma"     // Deviation from an exponentially weighted mean
+	AnomalyStrategySeasonal = "seasonal" // Robust z-score against the same hour of day
+)
+
+const (
+	defaultAnomalyThresholdMs = 1000.0
+	defaultAnomalyMinScore    = 3.5
+
+	baselineWindow     = 7 * 24 * time.Hour // History the baselines are computed from
+	baselineMaxSamples = 5000
+	baselineMinSamples = 20 // Below this, a phase is not scored
+	baselineRefresh    = 10 * time.Minute
+	baselineRetry      = time.Minute // Refresh of a baseline below baselineMinSamples
+	baselineMaxClients = 10000       // Baselines kept in memory
+	ewmaAlpha          = 0.05
+	minDeviationMs     = 1.0 // Floor of the spread, so that very stable phases do not flag jitter
+)
+
+// anomalyPhases are the timing phases scored against the baselines.
+var anomalyPhases = []struct {
+	name  string
+	value func(TimingMetrics) float64
+}{
+	{"dns", func(t TimingMetrics) float64 { return t.DNSLookupMs }},
+	{"tcp", func(t TimingMetrics) float64 { return t.TCPConnectMs }},
+	{"tls", func(t TimingMetrics) float64 { return t.TLSHandshakeMs }},
+	{"first_byte", func(t TimingMetrics) float64 { return t.FirstByteMs }},
+	{"total", func(t TimingMetrics) float64 { return t.TotalResponseMs }},
+}
+
+// validateAnomalyConfig checks an anomaly configuration and fills its defaults.
+func validateAnomalyConfig(cfg *AnomalyConfig) error {
+	switch cfg.Strategy {
+	case "":
+		cfg.Strategy = AnomalyStrategyMAD
+	case AnomalyStrategyFixed, AnomalyStrategyMAD, AnomalyStrategyEWMA, AnomalyStrategySeasonal:
+	default:
+		return errors.New("anomaly.strategy invalide (fixed, mad, ewma ou seasonal)")
+	}
+	if cfg.ThresholdMs < 0 || cfg.MinScore < 0 {
+		return errors.New("anomaly.threshold_ms et anomaly.min_score doivent être positifs")
+	}
+	if cfg.ThresholdMs == 0 {
+		cfg.ThresholdMs = defaultAnomalyThresholdMs
+	}
+	if cfg.MinScore == 0 {
+		cfg.MinScore = defaultAnomalyMinScore
+	}
+	return nil
+}
+
+// anomalyConfig returns the anomaly configuration of a check, defaults filled.
+func (def CheckDefinition) anomalyConfig() AnomalyConfig {
+	var cfg AnomalyConfig
+	if def.Anomaly != nil {
+		cfg = *def.Anomaly
+	}
+	if err := validateAnomalyConfig(&cfg); err != nil {
+		cfg = AnomalyConfig{}
+		validateAnomalyConfig(&cfg)
+	}
+	return cfg
+}
+
+// score returns how far a value deviates from the baseline, in robust
+// standard deviations (mad, seasonal) or standard deviations (ewma).
+func (b PhaseBaseline) score(strategy string, v float64) float64 {
+	if strategy == AnomalyStrategyEWMA {
+		return (v - b.EWMA) / math.Max(b.EWMStd, minDeviationMs)
+	}
+	return 0.6745 * (v - b.Median) / math.Max(b.MAD, minDeviationMs)
+}
+
+// phase returns the baseline a phase is scored against with a strategy, the
+// seasonal strategy falling back to the whole window for sparse hours.
+func (lb *LatencyBaseline) phase(strategy, name string, at time.Time) (PhaseBaseline, bool) {
+	if strategy == AnomalyStrategySeasonal {
+		if b, ok := lb.Hourly[at.Local().Hour()][name]; ok && b.Samples >= baselineMinSamples {
+			return b, true
+		}
+	}
+	b, ok := lb.Phases[name]
+	return b, ok && b.Samples >= baselineMinSamples
+}
+
+// newPhaseBaseline summarizes the values of a phase, in chronological order.
+func newPhaseBaseline(values []float64) PhaseBaseline {
+	b := PhaseBaseline{Samples: len(values)}
+	if len(values) == 0 {
+		return b
+	}
+
+	b.EWMA = values[0]
+	var variance float64
+	for _, v := range values[1:] {
+		diff := v - b.EWMA
+		b.EWMA += ewmaAlpha * diff
+		variance = (1 - ewmaAlpha) * (variance + ewmaAlpha*diff*diff)
+	}
+	b.EWMStd = math.Sqrt(variance)
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	b.Median = median(sorted)
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - b.Median)
+	}
+	sort.Float64s(deviations)
+	b.MAD = median(deviations)
+	return b
+}
+
+// median returns the median of sorted values.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// computeBaseline builds the baseline of a client from its successful samples
+// of the last baselineWindow, maintenance excluded. Phases a check does not
+// go through (zero durations) are left out.
+func (s *Server) computeBaseline(tenantID, clientID string) (*LatencyBaseline, error) {
+	rows, err := s.db.Query(`
+		SELECT timestamp, data FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND success = 1 AND maintenance = 0 AND timestamp > ?
+		ORDER BY timestamp DESC
+		LIMIT ?`,
+		tenantID, clientID, time.Now().Add(-baselineWindow), baselineMaxSamples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type sample struct {
+		at     time.Time
+		timing TimingMetrics
+	}
+	var samples []sample
+	for rows.Next() {
+		var ts time.Time
+		var dataStr string
+		if err := rows.Scan(&ts, &dataStr); err != nil {
+			log.Printf("Erreur de scan de l'historique du client %s: %v", clientID, err)
+			continue
+		}
+		var data MonitoringData
+		if err := json.Unmarshal([]byte(dataStr), &data); err != nil {
+			continue
+		}
+		samples = append(samples, sample{ts, data.TimingMetrics})
+	}
+
+	// Oldest first, as the moving averages expect
+	overall := make(map[string][]float64)
+	var hourly [24]map[string][]float64
+	for i := len(samples) - 1; i >= 0; i-- {
+		hour := samples[i].at.Local().Hour()
+		if hourly[hour] == nil {
+			hourly[hour] = make(map[string][]float64)
+		}
+		for _, phase := range anomalyPhases {
+			if v := phase.value(samples[i].timing); v > 0 {
+				overall[phase.name] = append(overall[phase.name], v)
+				hourly[hour][phase.name] = append(hourly[hour][phase.name], v)
+			}
+		}
+	}
+
+	lb := &LatencyBaseline{ClientID: clientID, ComputedAt: time.Now(), Phases: make(map[string]PhaseBaseline)}
+	for name, values := range overall {
+		lb.Phases[name] = newPhaseBaseline(values)
+	}
+	for hour, phases := range hourly {
+		lb.Hourly[hour] = make(map[string]PhaseBaseline)
+		for name, values := range phases {
+			lb.Hourly[hour][name] = newPhaseBaseline(values)
+		}
+	}
+	return lb, nil
+}
+
+// refresh returns how long a baseline is reused: baselineRefresh once it has
+// enough samples, baselineRetry until then so that it fills up quickly.
+func (lb *LatencyBaseline) refresh() time.Duration {
+	if lb.Phases["total"].Samples < baselineMinSamples {
+		return baselineRetry
+	}
+	return baselineRefresh
+}
+
+// clientBaseline returns the baseline of a client, recomputed from its
+// history when older than its refresh period. At most baselineMaxClients
+// baselines are kept, the oldest evicted first.
+func (s *Server) clientBaseline(tenantID, clientID string) (*LatencyBaseline, error) {
+	key := tenantID + "/" + clientID
+	s.baselineMu.Lock()
+	lb, ok := s.baselines[key]
+	s.baselineMu.Unlock()
+	if ok && time.Since(lb.ComputedAt) < lb.refresh() {
+		return lb, nil
+	}
+
+	lb, err := s.computeBaseline(tenantID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	s.baselineMu.Lock()
+	if _, ok := s.baselines[key]; !ok && len(s.baselines) >= baselineMaxClients {
+		s.evictBaselines()
+	}
+	s.baselines[key] = lb
+	s.baselineMu.Unlock()
+	return lb, nil
+}
+
+// evictBaselines makes room in the baseline cache by dropping the expired
+// baselines, or the oldest one if none has expired. baselineMu must be held.
+func (s *Server) evictBaselines() {
+	oldestKey, oldest := "", time.Time{}
+	for key, lb := range s.baselines {
+		if time.Since(lb.ComputedAt) >= lb.refresh() {
+			delete(s.baselines, key)
+		} else if oldestKey == "" || lb.ComputedAt.Before(oldest) {
+			oldestKey, oldest = key, lb.ComputedAt
+		}
+	}
+	if len(s.baselines) >= baselineMaxClients {
+		delete(s.baselines, oldestKey)
+	}
+}
+
+// scoreAnomaly flags a sample whose latency is abnormal for its client,
+// following the strategy of its check, and records the deviation score of
+// each timing phase. Adaptive strategies fall back to the fixed threshold
+// until the client has enough history.
+func (s *Server) scoreAnomaly(tenantID string, def CheckDefinition, data *MonitoringData) {
+	cfg := def.anomalyConfig()
+	total := data.TimingMetrics.TotalResponseMs
+	if cfg.Strategy == AnomalyStrategyFixed {
+		data.Anomalous = total > cfg.ThresholdMs
+		return
+	}
+
+	lb, err := s.clientBaseline(tenantID, data.ClientID)
+	if err != nil {
+		log.Printf("Erreur de calcul de la référence de latence du client %s: %v", data.ClientID, err)
+		data.Anomalous = total > cfg.ThresholdMs
+		return
+	}
+
+	now := time.Now()
+	scores := make(map[string]float64)
+	maxScore := math.Inf(-1)
+	for _, phase := range anomalyPhases {
+		v := phase.value(data.TimingMetrics)
+		b, ok := lb.phase(cfg.Strategy, phase.name, now)
+		if v <= 0 || !ok {
+			continue
+		}
+		score := math.Round(b.score(cfg.Strategy, v)*100) / 100
+		scores[phase.name] = score
+		maxScore = math.Max(maxScore, score)
+	}
+	if len(scores) == 0 {
+		data.Anomalous = total > cfg.ThresholdMs
+		return
+	}
+	data.PhaseScores = scores
+	data.AnomalyScore = maxScore
+	data.Anomalous = maxScore >= cfg.MinScore
+}
+
+// HandleBaseline returns the latency baseline of a client (GET ?client_id=).
+func (s *Server) HandleBaseline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID := r.URL.Query().Get("client_id")
+	if clientID == "" {
+		http.Error(w, "client_id requis", http.StatusBadRequest)
+		return
+	}
+	lb, err := s.clientBaseline(s.requestTenant(r), clientID)
+	if err != nil {
+		log.Printf("Erreur de calcul de la référence de latence du client %s: %v", clientID, err)
+		http.Error(w, "Erreur de calcul de la référence", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, lb)
+}
\ No newline at end of file
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewPhaseBaseline(t *testing.T) {
+	tests := []struct {
+		name                     string
+		values                   []float64
+		median, mad, ewma, ewStd float64
+	}{
+		{name: "empty"},
+		{name: "single", values: []float64{42}, median: 42, ewma: 42},
+		{name: "odd count with an outlier", values: []float64{3, 1, 100, 2, 4}, median: 3, mad: 1,
+			ewma: 7.2939, ewStd: 20.1597},
+		{name: "even count", values: []float64{10, 20}, median: 15, mad: 5, ewma: 10.5, ewStd: math.Sqrt(4.75)},
+		{name: "constant", values: []float64{7, 7, 7, 7}, median: 7, ewma: 7},
+	}
+
+	for _, tt := range tests {
+		b := newPhaseBaseline(tt.values)
+		if b.Samples != len(tt.values) {
+			t.Errorf("%s: samples = %d, want %d", tt.name, b.Samples, len(tt.values))
+		}
+		for _, f := range []struct {
+			field     string
+			got, want float64
+		}{{"median", b.Median, tt.median}, {"mad", b.MAD, tt.mad}, {"ewma", b.EWMA, tt.ewma}, {"ewm_std", b.EWMStd, tt.ewStd}} {
+			if math.Abs(f.got-f.want) > 1e-3 {
+				t.Errorf("%s: %s = %.4f, want %.4f", tt.name, f.field, f.got, f.want)
+			}
+		}
+	}
+}
+
+func TestPhaseBaselineScore(t *testing.T) {
+	b := PhaseBaseline{Median: 100, MAD: 10, EWMA: 50, EWMStd: 5}
+	stable := PhaseBaseline{Median: 100, EWMA: 100}
+
+	tests := []struct {
+		name     string
+		b        PhaseBaseline
+		strategy string
+		v, want  float64
+	}{
+		{"mad above", b, AnomalyStrategyMAD, 150, 0.6745 * 5},
+		{"mad below", b, AnomalyStrategyMAD, 90, -0.6745},
+		{"seasonal as mad", b, AnomalyStrategySeasonal, 150, 0.6745 * 5},
+		{"ewma", b, AnomalyStrategyEWMA, 65, 3},
+		{"mad floor", stable, AnomalyStrategyMAD, 102, 0.6745 * 2},
+		{"ewma floor", stable, AnomalyStrategyEWMA, 102, 2},
+	}
+
+	for _, tt := range tests {
+		if got := tt.b.score(tt.strategy, tt.v); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: score = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyBaselinePhase(t *testing.T) {
+	full := PhaseBaseline{Samples: baselineMinSamples, Median: 100}
+	hourFull := PhaseBaseline{Samples: baselineMinSamples, Median: 200}
+	sparse := PhaseBaseline{Samples: baselineMinSamples - 1, Median: 300}
+
+	at := time.Date(2026, 10, 18, 14, 30, 0, 0, time.Local)
+	lb := &LatencyBaseline{Phases: map[string]PhaseBaseline{"total": full, "dns": sparse, "tcp": full}}
+	lb.Hourly[14] = map[string]PhaseBaseline{"total": hourFull, "tcp": sparse}
+
+	tests := []struct {
+		name, strategy, phase string
+		at                    time.Time
+		want                  float64
+		wantOK                bool
+	}{
+		{"mad uses the window", AnomalyStrategyMAD, "total", at, 100, true},
+		{"seasonal uses the hour", AnomalyStrategySeasonal, "total", at, 200, true},
+		{"seasonal falls back for a sparse hour", AnomalyStrategySeasonal, "tcp", at, 100, true},
+		{"seasonal falls back for an empty hour", AnomalyStrategySeasonal, "total", at.Add(time.Hour), 100, true},
+		{"sparse window", AnomalyStrategySeasonal, "dns", at, 0, false},
+		{"unknown phase", AnomalyStrategyMAD, "tls", at, 0, false},
+	}
+
+	for _, tt := range tests {
+		b, ok := lb.phase(tt.strategy, tt.phase, tt.at)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if ok && b.Median != tt.want {
+			t.Errorf("%s: median = %v, want %v", tt.name, b.Median, tt.want)
+		}
+	}
+}
+
+func TestLatencyBaselineRefresh(t *testing.T) {
+	sparse := &LatencyBaseline{Phases: map[string]PhaseBaseline{"total": {Samples: 1}}}
+	if got := sparse.refresh(); got != baselineRetry {
+		t.Errorf("refresh of a sparse baseline = %s, want %s", got, baselineRetry)
+	}
+	full := &LatencyBaseline{Phases: map[string]PhaseBaseline{"total": {Samples: baselineMinSamples}}}
+	if got := full.refresh(); got != baselineRefresh {
+		t.Errorf("refresh of a full baseline = %s, want %s", got, baselineRefresh)
+	}
+}
+
+func TestEvictBaselines(t *testing.T) {
+	now := time.Now()
+	full := map[string]PhaseBaseline{"total": {Samples: baselineMinSamples}}
+	s := &Server{baselines: make(map[string]*LatencyBaseline)}
+	for i := 0; i < baselineMaxClients; i++ {
+		s.baselines[fmt.Sprint("t/", i)] = &LatencyBaseline{ComputedAt: now.Add(-time.Duration(i) * time.Millisecond), Phases: full}
+	}
+
+	s.evictBaselines()
+	if len(s.baselines) != baselineMaxClients-1 {
+		t.Fatalf("%d baselines after eviction, want %d", len(s.baselines), baselineMaxClients-1)
+	}
+	if _, ok := s.baselines[fmt.Sprint("t/", baselineMaxClients-1)]; ok {
+		t.Error("the oldest baseline was kept")
+	}
+
+	s.baselines["t/expired"] = &LatencyBaseline{ComputedAt: now.Add(-baselineRetry)}
+	s.baselines["t/stale"] = &LatencyBaseline{ComputedAt: now.Add(-baselineRefresh), Phases: full}
+	s.evictBaselines()
+	if len(s.baselines) != baselineMaxClients-1 {
+		t.Errorf("%d baselines after evicting the expired ones, want %d", len(s.baselines), baselineMaxClients-1)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// audit records an administrative action. Failures are logged but never
+// block the action itself.
+func (s *Server) audit(r *http.Request, tenantID, actor, action, target, details string) {
+	_, err := s.db.Exec(`
+		INSERT INTO audit_log (tenant_id, timestamp, actor, action, target, details, remote_addr)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		tenantID, time.Now(), actor, action, target, details, r.RemoteAddr)
+	if err != nil {
+		log.Printf("Erreur d'écriture du journal d'audit (%s par %s): %v", action, actor, err)
+	}
+}
+
+// getAuditLog returns the most recent audit entries of a tenant, or of all
+// tenants when tenantID is empty.
+func (s *Server) getAuditLog(tenantID string, limit int) ([]AuditEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, timestamp, actor, action, target, details, remote_addr
+		FROM audit_log
+		WHERE ? = '' OR tenant_id = ?
+		ORDER BY timestamp DESC
+		LIMIT ?`, tenantID, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Timestamp, &e.Actor, &e.Action, &e.Target, &e.Details, &e.RemoteAddr); err != nil {
+			log.Printf("Erreur de scan du journal d'audit: %v", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// HandleAdminAudit returns the audit trail (GET ?limit=).
+func (s *Server) HandleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 200
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	entries, err := s.getAuditLog(adminTenantScope(r), limit)
+	if err != nil {
+		log.Printf("Erreur de récupération du journal d'audit: %v", err)
+		http.Error(w, "Erreur de récupération du journal d'audit", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
\ No newline at end of file
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// certRenewalWindow is how close to expiry a certificate must be for its
+// replacement by one from the same issuer to count as a routine renewal.
+const certRenewalWindow = 30 * 24 * time.Hour
+
+// chainFingerprint identifies a certificate chain. Probes that do not send
+// fingerprints fall back to the subject, issuer and serial of each certificate.
+func chainFingerprint(chain []TLSCertificate) string {
+	h := sha256.New()
+	for _, c := range chain {
+		if c.FingerprintSHA256 != "" {
+			h.Write([]byte(strings.ToLower(c.FingerprintSHA256)))
+		} else {
+			h.Write([]byte(c.Subject + "|" + c.Issuer + "|" + c.SerialNumber))
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isRenewal reports whether replacing previous by current looks like a
+// routine renewal: same issuer and subject, previous leaf close to expiry.
+func isRenewal(previous, current []TLSCertificate, changedAt time.Time) bool {
+	if len(previous) == 0 || len(current) == 0 {
+		return false
+	}
+	old, cur := previous[0], current[0]
+	return old.Issuer == cur.Issuer &&
+		old.Subject == cur.Subject &&
+		old.NotAfter.Sub(changedAt) <= certRenewalWindow
+}
+
+// storeCertificate records the certificate chain presented by a client's
+// target. A different chain than the stored one is kept as a change, flagged
+// as unexpected unless it looks like a renewal.
+func (s *Server) storeCertificate(tenantID string, data MonitoringData) error {
+	tlsInfo := data.NetworkInfo.TLS
+	if tlsInfo == nil || len(tlsInfo.Chain) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	fingerprint := chainFingerprint(tlsInfo.Chain)
+	chainJSON, _ := json.Marshal(tlsInfo.Chain)
+
+	var storedFingerprint, storedChain string
+	err := s.db.QueryRow(`
+		SELECT chain_fingerprint, chain FROM target_certificates
+		WHERE tenant_id = ? AND client_id = ? AND target_url = ?`,
+		tenantID, data.ClientID, data.TargetURL).Scan(&storedFingerprint, &storedChain)
+
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = s.db.Exec(`
+			INSERT INTO target_certificates (tenant_id, client_id, target_url, chain_fingerprint, not_after, tls_version, cipher_suite, chain, first_seen, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tenantID, data.ClientID, data.TargetURL, fingerprint, tlsInfo.Chain[0].NotAfter,
+			tlsInfo.Version, tlsInfo.CipherSuite, string(chainJSON), now, now)
+		return err
+
+	case err != nil:
+		return err
+
+	case storedFingerprint == fingerprint:
+		_, err = s.db.Exec(`
+			UPDATE target_certificates SET last_seen = ?, tls_version = ?, cipher_suite = ?
+			WHERE tenant_id = ? AND client_id = ? AND target_url = ?`,
+			now, tlsInfo.Version, tlsInfo.CipherSuite, tenantID, data.ClientID, data.TargetURL)
+		return err
+	}
+
+	var previous []TLSCertificate
+	if err := json.Unmarshal([]byte(storedChain), &previous); err != nil {
+		log.Printf("Erreur de décodage de la chaîne précédente de %s (%s): %v", data.ClientID, data.TargetURL, err)
+	}
+	unexpected := !isRenewal(previous, tlsInfo.Chain, now)
+	if unexpected {
+		log.Printf("Changement inattendu de la chaîne de certificats de %s (%s)", data.ClientID, data.TargetURL)
+	} else {
+		log.Printf("Certificat renouvelé pour %s (%s)", data.ClientID, data.TargetURL)
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE target_certificates SET
+			chain_fingerprint = ?, not_after = ?, tls_version = ?, cipher_suite = ?, chain = ?,
+			last_seen = ?, previous_chain = ?, changed_at = ?, unexpected_change = ?
+		WHERE tenant_id = ? AND client_id = ? AND target_url = ?`,
+		fingerprint, tlsInfo.Chain[0].NotAfter, tlsInfo.Version, tlsInfo.CipherSuite, string(chainJSON),
+		now, storedChain, now, unexpected, tenantID, data.ClientID, data.TargetURL)
+	return err
+}
+
+// getTargetCertificates returns the certificates seen on the targets of a
+// tenant, optionally for a single client, soonest expiry first.
+func (s *Server) getTargetCertificates(tenantID, clientID string) ([]TargetCertificate, error) {
+	rows, err := s.db.Query(`
+		SELECT tenant_id, client_id, target_url, chain_fingerprint, not_after, tls_version, cipher_suite,
+		       chain, first_seen, last_seen, previous_chain, changed_at, unexpected_change
+		FROM target_certificates
+		WHERE tenant_id = ? AND (? = '' OR client_id = ?)
+		ORDER BY not_after ASC`,
+		tenantID, clientID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certs := []TargetCertificate{}
+	for rows.Next() {
+		var c TargetCertificate
+		var chain string
+		var tlsVersion, cipherSuite, previousChain sql.NullString
+		var changedAt sql.NullTime
+		if err := rows.Scan(&c.TenantID, &c.ClientID, &c.TargetURL, &c.ChainFingerprint, &c.NotAfter,
+			&tlsVersion, &cipherSuite, &chain, &c.FirstSeen, &c.LastSeen, &previousChain, &changedAt, &c.UnexpectedChange); err != nil {
+			log.Printf("Erreur de scan des certificats: %v", err)
+			continue
+		}
+		if err := json.Unmarshal([]byte(chain), &c.Chain); err != nil {
+			log.Printf("Erreur de décodage de la chaîne de %s (%s): %v", c.ClientID, c.TargetURL, err)
+			continue
+		}
+		if previousChain.Valid {
+			json.Unmarshal([]byte(previousChain.String), &c.PreviousChain)
+		}
+		if changedAt.Valid {
+			c.ChangedAt = &changedAt.Time
+		}
+		c.TLSVersion = tlsVersion.String
+		c.CipherSuite = cipherSuite.String
+		c.DaysRemaining = int(math.Floor(time.Until(c.NotAfter).Hours() / 24))
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
+
+// HandleGetCertificates returns the certificates of the tenant's targets as
+// JSON (GET ?client_id=).
+func (s *Server) HandleGetCertificates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	certs, err := s.getTargetCertificates(s.requestTenant(r), r.URL.Query().Get("client_id"))
+	if err != nil {
+		log.Printf("Erreur de récupération des certificats: %v", err)
+		http.Error(w, "Erreur de récupération des certificats", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, certs)
+}
+
+// HandleCertificatesPage renders the certificates view with the firing
+// certificate alerts of the tenant.
+func (s *Server) HandleCertificatesPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID := s.requestTenant(r)
+	certs, err := s.getTargetCertificates(tenantID, "")
+	if err != nil {
+		log.Printf("Erreur de récupération des certificats: %v", err)
+		http.Error(w, "Erreur de récupération des certificats", http.StatusInternalServerError)
+		return
+	}
+	alerts, err := s.listAlerts(tenantID, AlertStateFiring, 200)
+	if err != nil {
+		log.Printf("Erreur de récupération des alertes: %v", err)
+	}
+	var certAlerts []Alert
+	for _, a := range alerts {
+		if a.Type == AlertTypeCertExpiry || a.Type == AlertTypeCertChainChanged {
+			certAlerts = append(certAlerts, a)
+		}
+	}
+	sort.Slice(certAlerts, func(i, j int) bool { return certAlerts[i].StartedAt.After(certAlerts[j].StartedAt) })
+
+	tmpl, err := template.New("certificates.html").
+		Funcs(template.FuncMap{
+			"formatTime": func(t time.Time) string {
+				return t.Format("02/01/2006 15:04")
+			},
+		}).
+		ParseFiles("templates/certificates.html")
+	if err != nil {
+		log.Printf("Erreur de chargement du template des certificats: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := struct {
+		CurrentUser   *User
+		CurrentTenant string
+		Certificates  []TargetCertificate
+		Alerts        []Alert
+	}{
+		CurrentUser:   userFromContext(r.Context()),
+		CurrentTenant: tenantID,
+		Certificates:  certs,
+		Alerts:        certAlerts,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := tmpl.Execute(w, pageData); err != nil {
+		log.Printf("Erreur lors de l'exécution du template des certificats: %v", err)
+	}
+}
\ No newline at end of file
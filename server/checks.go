@@ -0,0 +1,546 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Assertion types.
+const (
+	AssertStatusCode   = "status_code"
+	AssertBodyContains = "body_contains"
+	AssertBodyRegex    = "body_regex"
+	AssertJSONPath     = "json_path"
+	AssertHeader       = "header"
+	AssertMaxBodySize  = "max_body_size"
+	AssertMaxLatency   = "max_latency"
+)
+
+var errUnknownCheck = errors.New("aucun check défini pour ce client")
+
+// validateAssertion checks that an assertion is well formed before it is saved.
+func validateAssertion(a Assertion) error {
+	switch a.Type {
+	case AssertStatusCode:
+		_, err := parseStatusSpec(a.Value)
+		return err
+	case AssertBodyContains:
+		if a.Value == "" {
+			return errors.New("body_contains: value requis")
+		}
+	case AssertBodyRegex:
+		_, err := regexp.Compile(a.Value)
+		return err
+	case AssertJSONPath, AssertHeader:
+		if a.Property == "" {
+			return fmt.Errorf("%s: property requis", a.Type)
+		}
+		if a.Type == AssertHeader && a.Value != "" {
+			_, err := regexp.Compile(a.Value)
+			return err
+		}
+	case AssertMaxBodySize, AssertMaxLatency:
+		if a.Max <= 0 {
+			return fmt.Errorf("%s: max doit être positif", a.Type)
+		}
+	default:
+		return fmt.Errorf("type d'assertion inconnu: %s", a.Type)
+	}
+	return nil
+}
+
+// validateJourneyStep checks a journey step and fills its defaults.
+func validateJourneyStep(step *JourneyStep) error {
+	if step.URL == "" {
+		return errors.New("url requise")
+	}
+	step.Method = strings.ToUpper(step.Method)
+	if step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	for _, e := range step.Extract {
+		if e.Name == "" || e.Expression == "" {
+			return errors.New("extraction: name et expression requis")
+		}
+		switch e.Source {
+		case "json_path", "header":
+		case "regex":
+			re, err := regexp.Compile(e.Expression)
+			if err != nil {
+				return err
+			}
+			if re.NumSubexp() < 1 {
+				return fmt.Errorf("extraction %s: la regex doit avoir un groupe de capture", e.Name)
+			}
+		default:
+			return fmt.Errorf("extraction %s: source inconnue %q", e.Name, e.Source)
+		}
+	}
+	for _, a := range step.Assertions {
+		if err := validateAssertion(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusRange is an inclusive range of HTTP status codes.
+type statusRange struct{ min, max int }
+
+// parseStatusSpec parses a list of status codes such as "200,204,3xx,400-404".
+func parseStatusSpec(spec string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		if len(part) == 3 && strings.HasSuffix(part, "xx") && part[0] >= '1' && part[0] <= '5' {
+			base := int(part[0]-'0') * 100
+			ranges = append(ranges, statusRange{base, base + 99})
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		min, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("code de statut invalide: %s", part)
+		}
+		max := min
+		if len(bounds) == 2 {
+			if max, err = strconv.Atoi(bounds[1]); err != nil || max < min {
+				return nil, fmt.Errorf("plage de statuts invalide: %s", part)
+			}
+		}
+		ranges = append(ranges, statusRange{min, max})
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("status_code: value requis (ex. \"200,3xx\")")
+	}
+	return ranges, nil
+}
+
+// lookupJSONPath resolves a dotted path such as "$.data.items[0].id" in a
+// decoded JSON document.
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, true
+	}
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonValueString formats a decoded JSON value for comparison with an
+// expected value: strings unquoted, numbers without trailing zeros.
+func jsonValueString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+// evaluateAssertion evaluates an assertion against a sample. Body assertions
+// only see BodyPreview, the part of the body the probe sends.
+func evaluateAssertion(a Assertion, data *MonitoringData) AssertionResult {
+	res := AssertionResult{Assertion: a}
+	resp := data.ResponseDetails
+	truncated := ""
+	if resp.BodySize > int64(len(resp.BodyPreview)) {
+		truncated = " (aperçu du corps tronqué)"
+	}
+
+	switch a.Type {
+	case AssertStatusCode:
+		res.Actual = strconv.Itoa(resp.StatusCode)
+		ranges, _ := parseStatusSpec(a.Value)
+		for _, r := range ranges {
+			if resp.StatusCode >= r.min && resp.StatusCode <= r.max {
+				res.Passed = true
+			}
+		}
+		res.Message = fmt.Sprintf("statut %d hors de %s", resp.StatusCode, a.Value)
+
+	case AssertBodyContains:
+		res.Passed = strings.Contains(resp.BodyPreview, a.Value)
+		res.Message = fmt.Sprintf("corps sans %q%s", a.Value, truncated)
+
+	case AssertBodyRegex:
+		re, err := regexp.Compile(a.Value)
+		res.Passed = err == nil && re.MatchString(resp.BodyPreview)
+		res.Message = fmt.Sprintf("corps ne correspondant pas à /%s/%s", a.Value, truncated)
+
+	case AssertJSONPath:
+		var doc interface{}
+		if err := json.Unmarshal([]byte(resp.BodyPreview), &doc); err != nil {
+			res.Message = "corps JSON invalide" + truncated
+			break
+		}
+		v, ok := lookupJSONPath(doc, a.Property)
+		if !ok {
+			res.Message = a.Property + " absent du corps JSON"
+			break
+		}
+		res.Actual = jsonValueString(v)
+		res.Passed = res.Actual == a.Value
+		res.Message = fmt.Sprintf("%s = %s, attendu %s", a.Property, res.Actual, a.Value)
+
+	case AssertHeader:
+		value, found := "", false
+		for name, v := range resp.HeadersReceived {
+			if strings.EqualFold(name, a.Property) {
+				value, found = v, true
+				break
+			}
+		}
+		res.Actual = value
+		if !found {
+			res.Message = "en-tête " + a.Property + " absent"
+			break
+		}
+		if a.Value == "" {
+			res.Passed = true
+			break
+		}
+		re, err := regexp.Compile(a.Value)
+		res.Passed = err == nil && re.MatchString(value)
+		res.Message = fmt.Sprintf("en-tête %s = %q ne correspondant pas à /%s/", a.Property, value, a.Value)
+
+	case AssertMaxBodySize:
+		res.Actual = strconv.FormatInt(resp.BodySize, 10)
+		res.Passed = float64(resp.BodySize) <= a.Max
+		res.Message = fmt.Sprintf("corps de %d octets, maximum %.0f", resp.BodySize, a.Max)
+
+	case AssertMaxLatency:
+		latency := data.TimingMetrics.TotalResponseMs
+		res.Actual = strconv.FormatFloat(latency, 'f', 1, 64)
+		res.Passed = latency <= a.Max
+		res.Message = fmt.Sprintf("latence de %.1fms, maximum %.0fms", latency, a.Max)
+
+	default:
+		res.Message = "type d'assertion inconnu: " + a.Type
+	}
+
+	if res.Passed {
+		res.Message = ""
+	} else {
+		res.ErrorType = "assertion_" + a.Type
+	}
+	return res
+}
+
+// applyAssertions applies the assertions of the client's check to a sample
+// and, for journeys, to each of its steps.
+func applyAssertions(def CheckDefinition, data *MonitoringData) {
+	if data.CheckType == CheckTypeJourney {
+		for i := range data.Steps {
+			step := &data.Steps[i]
+			var assertions []Assertion
+			if i < len(def.Steps) {
+				assertions = def.Steps[i].Assertions
+			}
+			sample := MonitoringData{
+				CheckType:        CheckTypeHTTP,
+				TimingMetrics:    step.TimingMetrics,
+				ResponseDetails:  step.ResponseDetails,
+				ErrorDetails:     step.ErrorDetails,
+				AssertionResults: step.AssertionResults,
+			}
+			evaluateAssertions(assertions, &sample)
+			step.AssertionResults, step.ErrorDetails = sample.AssertionResults, sample.ErrorDetails
+		}
+		journeyStepError(data)
+	}
+	evaluateAssertions(def.Assertions, data)
+}
+
+// evaluateAssertions evaluates the assertions the probe did not evaluate
+// itself, and turns the first failed assertion into the sample error.
+// Samples that already failed (timeout, refused connection...) keep their
+// own error.
+func evaluateAssertions(assertions []Assertion, data *MonitoringData) {
+	for i := range data.AssertionResults {
+		r := &data.AssertionResults[i]
+		if !r.Passed && r.ErrorType == "" {
+			r.ErrorType = "assertion_" + r.Type
+		}
+	}
+
+	if !data.ErrorDetails.HasError {
+		for _, a := range assertions {
+			if a.Type != AssertMaxLatency && !data.IsHTTPCheck() {
+				continue
+			}
+			evaluated := false
+			for _, r := range data.AssertionResults {
+				if r.Assertion == a {
+					evaluated = true
+					break
+				}
+			}
+			if !evaluated {
+				data.AssertionResults = append(data.AssertionResults, evaluateAssertion(a, data))
+			}
+		}
+	}
+
+	var failed []string
+	for _, r := range data.AssertionResults {
+		if r.Passed {
+			continue
+		}
+		if !data.ErrorDetails.HasError {
+			data.ErrorDetails.HasError = true
+			data.ErrorDetails.ErrorType = r.ErrorType
+		}
+		failed = append(failed, r.Message)
+	}
+	if len(failed) > 0 && strings.HasPrefix(data.ErrorDetails.ErrorType, "assertion_") {
+		data.ErrorDetails.ErrorMessage = strings.Join(failed, "; ")
+	}
+}
+
+// getCheckDefinition returns the check defined for a client.
+func (s *Server) getCheckDefinition(tenantID, clientID string) (CheckDefinition, error) {
+	var def CheckDefinition
+	var definition string
+	err := s.db.QueryRow(`
+		SELECT definition, updated_at FROM checks
+		WHERE tenant_id = ? AND client_id = ?`,
+		tenantID, clientID).Scan(&definition, &def.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return def, errUnknownCheck
+	}
+	if err != nil {
+		return def, err
+	}
+	updatedAt := def.UpdatedAt
+	if err := json.Unmarshal([]byte(definition), &def); err != nil {
+		return def, err
+	}
+	def.TenantID, def.ClientID, def.UpdatedAt = tenantID, clientID, updatedAt
+	return def, nil
+}
+
+// listCheckDefinitions returns the checks defined in a tenant.
+func (s *Server) listCheckDefinitions(tenantID string) ([]CheckDefinition, error) {
+	rows, err := s.db.Query(`
+		SELECT client_id, definition, updated_at FROM checks
+		WHERE tenant_id = ?
+		ORDER BY client_id`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := []CheckDefinition{}
+	for rows.Next() {
+		var def CheckDefinition
+		var clientID, definition string
+		var updatedAt time.Time
+		if err := rows.Scan(&clientID, &definition, &updatedAt); err != nil {
+			log.Printf("Erreur de scan des checks: %v", err)
+			continue
+		}
+		if err := json.Unmarshal([]byte(definition), &def); err != nil {
+			log.Printf("Erreur de décodage du check du client %s: %v", clientID, err)
+			continue
+		}
+		def.TenantID, def.ClientID, def.UpdatedAt = tenantID, clientID, updatedAt
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// saveCheckDefinition validates and stores the check of a client.
+func (s *Server) saveCheckDefinition(def *CheckDefinition) error {
+	if def.ClientID == "" {
+		return errors.New("client_id requis")
+	}
+	if def.CheckType == "" {
+		def.CheckType = CheckTypeHTTP
+	}
+	switch def.CheckType {
+	case CheckTypeHTTP, CheckTypeTCP, CheckTypeDNS, CheckTypeGRPC, CheckTypeJourney:
+	default:
+		return fmt.Errorf("check_type inconnu: %s", def.CheckType)
+	}
+	if def.Interval < 0 {
+		return errors.New("interval_seconds invalide")
+	}
+	for _, a := range def.Assertions {
+		if err := validateAssertion(a); err != nil {
+			return err
+		}
+	}
+	if pt := def.PathTrace; pt != nil {
+		if pt.IntervalMinutes < 0 || pt.MaxHops < 0 || pt.MaxHops > 64 || pt.Port < 0 || pt.Port > 65535 {
+			return errors.New("path_trace: interval_minutes, max_hops (≤ 64) ou port invalide")
+		}
+		if pt.MaxHops == 0 {
+			pt.MaxHops = 30
+		}
+	}
+	if def.Anomaly != nil {
+		if err := validateAnomalyConfig(def.Anomaly); err != nil {
+			return err
+		}
+	}
+	if err := validateExpectedIPs(def.ExpectedIPs); err != nil {
+		return err
+	}
+	if def.CheckType == CheckTypeJourney {
+		if len(def.Steps) == 0 {
+			return errors.New("un parcours doit comporter au moins une étape")
+		}
+		for i := range def.Steps {
+			if err := validateJourneyStep(&def.Steps[i]); err != nil {
+				return fmt.Errorf("étape %d: %w", i+1, err)
+			}
+		}
+	}
+
+	def.UpdatedAt = time.Now()
+	definition, _ := json.Marshal(def)
+	_, err := s.db.Exec(`
+		INSERT INTO checks (tenant_id, client_id, definition, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(tenant_id, client_id) DO UPDATE SET
+			definition = excluded.definition,
+			updated_at = excluded.updated_at`,
+		def.TenantID, def.ClientID, string(definition), def.UpdatedAt)
+	return err
+}
+
+// HandleChecks lists check definitions (GET ?client_id=); operators can also
+// create or replace (PUT) and delete (DELETE ?client_id=) them.
+func (s *Server) HandleChecks(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	tenantID := s.requestTenant(r)
+	if r.Method != http.MethodGet && !user.hasRole(RoleOperator) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if clientID := r.URL.Query().Get("client_id"); clientID != "" {
+			def, err := s.getCheckDefinition(tenantID, clientID)
+			if err == errUnknownCheck {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Erreur de récupération du check du client %s: %v", clientID, err)
+				http.Error(w, "Erreur de récupération du check", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, def)
+			return
+		}
+		defs, err := s.listCheckDefinitions(tenantID)
+		if err != nil {
+			log.Printf("Erreur de récupération des checks: %v", err)
+			http.Error(w, "Erreur de récupération des checks", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, defs)
+
+	case http.MethodPut:
+		var def CheckDefinition
+		if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		def.TenantID = tenantID
+		if err := s.saveCheckDefinition(&def); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "check_save", def.ClientID, fmt.Sprintf("type=%s assertions=%d", def.CheckType, len(def.Assertions)))
+		writeJSON(w, http.StatusOK, def)
+
+	case http.MethodDelete:
+		clientID := r.URL.Query().Get("client_id")
+		res, err := s.db.Exec(`DELETE FROM checks WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID)
+		if err != nil {
+			log.Printf("Erreur de suppression du check du client %s: %v", clientID, err)
+			http.Error(w, "Erreur de suppression du check", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, errUnknownCheck.Error(), http.StatusNotFound)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "check_delete", clientID, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleProbeCheck returns the check definition of the authenticated probe's
+// client, so probes can run the check and evaluate its assertions.
+func (s *Server) HandleProbeCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, err := s.authenticateProbe(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="probes"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	def, err := s.getCheckDefinition(token.TenantID, token.ClientID)
+	if err == errUnknownCheck {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur de récupération du check du client %s: %v", token.ClientID, err)
+		http.Error(w, "Erreur de récupération du check", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, def)
+}
\ No newline at end of file
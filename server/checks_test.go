@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []statusRange
+		wantErr bool
+	}{
+		{spec: "200", want: []statusRange{{200, 200}}},
+		{spec: "2xx", want: []statusRange{{200, 299}}},
+		{spec: " 200, 3XX ,400-404", want: []statusRange{{200, 200}, {300, 399}, {400, 404}}},
+		{spec: "200,,204", want: []statusRange{{200, 200}, {204, 204}}},
+		{spec: "5xx,418", want: []statusRange{{500, 599}, {418, 418}}},
+		{spec: "6xx", wantErr: true},
+		{spec: "xx", wantErr: true},
+		{spec: "404-400", wantErr: true},
+		{spec: "200-abc", wantErr: true},
+		{spec: "ok", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: " , ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStatusSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStatusSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStatusSpec(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{
+		"status": "ok",
+		"count": 2,
+		"data": {"items": [{"id": 7}, {"id": 8, "tags": ["a", "b"]}]},
+		"empty": null
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{path: "$.status", want: "ok", wantOK: true},
+		{path: "status", want: "ok", wantOK: true},
+		{path: "$.count", want: "2", wantOK: true},
+		{path: "$.data.items[0].id", want: "7", wantOK: true},
+		{path: "$.data.items[1].tags[1]", want: "b", wantOK: true},
+		{path: "$.data.items.1.id", want: "8", wantOK: true},
+		{path: "$.empty", want: "null", wantOK: true},
+		{path: "$.data.items[2].id"},
+		{path: "$.data.items[-1]"},
+		{path: "$.data.items[x]"},
+		{path: "$.missing"},
+		{path: "$.status.length"},
+	}
+
+	for _, tt := range tests {
+		got, ok := lookupJSONPath(doc, tt.path)
+		if ok != tt.wantOK {
+			t.Errorf("lookupJSONPath(%q) ok = %v, want %v", tt.path, ok, tt.wantOK)
+			continue
+		}
+		if ok && jsonValueString(got) != tt.want {
+			t.Errorf("lookupJSONPath(%q) = %s, want %s", tt.path, jsonValueString(got), tt.want)
+		}
+	}
+
+	if got, ok := lookupJSONPath(doc, "$"); !ok || !reflect.DeepEqual(got, doc) {
+		t.Errorf("lookupJSONPath($) = %v, %v, want the document", got, ok)
+	}
+}
+
+func TestSaveCheckDefinitionRejectsUnknownType(t *testing.T) {
+	s := &Server{}
+	err := s.saveCheckDefinition(&CheckDefinition{ClientID: "c1", CheckType: "foo"})
+	if err == nil {
+		t.Fatal("saveCheckDefinition accepted check_type foo")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Check types reported in MonitoringData.CheckType.
+const (
+	CheckTypeHTTP = "http"
+	CheckTypeTCP  = "tcp"
+	CheckTypeDNS  = "dns"
+	CheckTypeGRPC = "grpc"
+
+	CheckTypeJourney = "journey"
+)
+
+// IsHTTPCheck reports whether the HTTP-only fields (status code, headers,
+// body) of a sample are meaningful. Samples from probes predating check
+// types have no CheckType and are HTTP checks.
+func (d MonitoringData) IsHTTPCheck() bool {
+	return d.CheckType == "" || d.CheckType == CheckTypeHTTP
+}
+
+// normalizeMonitoringData fills defaults and applies the server-side rules of
+// each check type before a sample is stored.
+func normalizeMonitoringData(data *MonitoringData) {
+	if data.CheckType == "" {
+		data.CheckType = CheckTypeHTTP
+	}
+	if data.PathTrace != nil && data.PathTrace.DestinationIP == "" {
+		data.PathTrace.DestinationIP = data.NetworkInfo.RemoteIP
+	}
+
+	switch data.CheckType {
+	case CheckTypeTCP:
+		normalizeTCPData(data)
+	case CheckTypeDNS:
+		normalizeDNSData(data)
+	case CheckTypeGRPC:
+		normalizeGRPCData(data)
+	case CheckTypeJourney:
+		normalizeJourneyData(data)
+	}
+}
+
+// normalizeTCPData makes the TCP connect time the sample latency when the
+// probe did not report a total, and evaluates the banner expectation when the
+// probe did not.
+func normalizeTCPData(data *MonitoringData) {
+	tcp := data.TCPDetails
+	if tcp == nil {
+		return
+	}
+
+	if data.TimingMetrics.TotalResponseMs == 0 {
+		data.TimingMetrics.TotalResponseMs = tcp.ConnectMs
+	}
+	if data.TimingMetrics.TCPConnectMs == 0 {
+		data.TimingMetrics.TCPConnectMs = tcp.ConnectMs
+	}
+
+	if tcp.ExpectString == "" || data.ErrorDetails.HasError {
+		return
+	}
+	if tcp.ExpectMatched == nil {
+		matched := tcp.BannerRead && strings.Contains(tcp.Banner, tcp.ExpectString)
+		tcp.ExpectMatched = &matched
+	}
+	if !*tcp.ExpectMatched {
+		data.ErrorDetails.HasError = true
+		data.ErrorDetails.ErrorType = "banner_mismatch"
+		data.ErrorDetails.ErrorMessage = "Bannière sans la chaîne attendue: " + tcp.ExpectString
+	}
+}
+
+// normalizeDNSData makes the DNS response time the sample latency, turns a
+// non-NOERROR rcode into an error and evaluates the expected answers.
+func normalizeDNSData(data *MonitoringData) {
+	dns := data.DNSDetails
+	if dns == nil {
+		return
+	}
+
+	if data.TimingMetrics.TotalResponseMs == 0 {
+		data.TimingMetrics.TotalResponseMs = dns.ResponseMs
+	}
+	if data.TimingMetrics.DNSLookupMs == 0 {
+		data.TimingMetrics.DNSLookupMs = dns.ResponseMs
+	}
+	dns.RecordType = strings.ToUpper(dns.RecordType)
+	dns.Rcode = strings.ToUpper(dns.Rcode)
+
+	if data.ErrorDetails.HasError {
+		return
+	}
+	if dns.Rcode != "" && dns.Rcode != "NOERROR" {
+		data.ErrorDetails.HasError = true
+		data.ErrorDetails.ErrorType = "dns_" + strings.ToLower(dns.Rcode)
+		data.ErrorDetails.ErrorMessage = fmt.Sprintf("Réponse %s pour %s %s", dns.Rcode, dns.RecordType, dns.QueryName)
+		return
+	}
+	if len(dns.Expected) == 0 {
+		return
+	}
+
+	answers := make(map[string]bool, len(dns.Answers))
+	for _, a := range dns.Answers {
+		answers[normalizeDNSValue(a)] = true
+	}
+	expected := make(map[string]bool, len(dns.Expected))
+	dns.MissingExpected = nil
+	for _, e := range dns.Expected {
+		e = normalizeDNSValue(e)
+		expected[e] = true
+		if !answers[e] {
+			dns.MissingExpected = append(dns.MissingExpected, e)
+		}
+	}
+
+	var passed bool
+	switch dns.ExpectMode {
+	case "all":
+		passed = len(dns.MissingExpected) == 0
+	case "exact":
+		dns.UnexpectedAnswers = nil
+		for a := range answers {
+			if !expected[a] {
+				dns.UnexpectedAnswers = append(dns.UnexpectedAnswers, a)
+			}
+		}
+		sort.Strings(dns.UnexpectedAnswers)
+		passed = len(dns.MissingExpected) == 0 && len(dns.UnexpectedAnswers) == 0
+	default:
+		passed = len(dns.MissingExpected) < len(expected)
+	}
+	dns.AssertionPassed = &passed
+
+	if !passed {
+		data.ErrorDetails.HasError = true
+		data.ErrorDetails.ErrorType = "dns_assertion_failed"
+		data.ErrorDetails.ErrorMessage = fmt.Sprintf("%s %s: réponses %v, attendu %v (%s)",
+			dns.RecordType, dns.QueryName, dns.Answers, dns.Expected, expectModeOrDefault(dns.ExpectMode))
+	}
+}
+
+// normalizeDNSValue makes answers comparable: case-insensitive, without the
+// trailing dot of fully qualified names and with single spaces (MX records).
+func normalizeDNSValue(v string) string {
+	v = strings.Join(strings.Fields(strings.ToLower(v)), " ")
+	return strings.TrimSuffix(v, ".")
+}
+
+func expectModeOrDefault(mode string) string {
+	if mode == "" {
+		return "any"
+	}
+	return mode
+}
+
+// normalizeGRPCData maps a health check call onto the timing and error model:
+// connect time as TCP connect, connect plus RPC as latency, a failed call or a
+// service not SERVING as an error.
+func normalizeGRPCData(data *MonitoringData) {
+	g := data.GRPCDetails
+	if g == nil {
+		return
+	}
+
+	if data.TimingMetrics.TotalResponseMs == 0 {
+		data.TimingMetrics.TotalResponseMs = g.ConnectMs + g.RPCMs
+	}
+	if data.TimingMetrics.TCPConnectMs == 0 {
+		data.TimingMetrics.TCPConnectMs = g.ConnectMs
+	}
+	if data.TimingMetrics.FirstByteMs == 0 {
+		data.TimingMetrics.FirstByteMs = g.ConnectMs + g.RPCMs
+	}
+	g.StatusCode = strings.ToUpper(g.StatusCode)
+	g.ServingStatus = strings.ToUpper(g.ServingStatus)
+	if g.StatusCode == "" && g.ServingStatus != "" {
+		g.StatusCode = "OK"
+	}
+
+	if data.ErrorDetails.HasError {
+		return
+	}
+	switch {
+	case g.StatusCode != "OK":
+		data.ErrorDetails.HasError = true
+		data.ErrorDetails.ErrorType = "grpc_" + strings.ToLower(g.StatusCode)
+		data.ErrorDetails.ErrorMessage = fmt.Sprintf("Appel Health/Check sur %s en échec: %s %s", g.Target, g.StatusCode, g.StatusMessage)
+	case g.ServingStatus != "SERVING":
+		data.ErrorDetails.HasError = true
+		data.ErrorDetails.ErrorType = "grpc_not_serving"
+		data.ErrorDetails.ErrorMessage = fmt.Sprintf("Service %q sur %s: %s", g.Service, g.Target, g.ServingStatus)
+	}
+}
+
+// normalizeJourneyData fills the step start offsets and the run latency when
+// the probe did not report them, assuming the steps ran one after another.
+func normalizeJourneyData(data *MonitoringData) {
+	end := 0.0
+	for i := range data.Steps {
+		step := &data.Steps[i]
+		if step.Method == "" {
+			step.Method = "GET"
+		}
+		if step.StartOffsetMs == 0 {
+			step.StartOffsetMs = end
+		}
+		if stepEnd := step.StartOffsetMs + step.TimingMetrics.TotalResponseMs; stepEnd > end {
+			end = stepEnd
+		}
+	}
+	if data.TimingMetrics.TotalResponseMs == 0 {
+		data.TimingMetrics.TotalResponseMs = end
+	}
+}
+
+// journeyStepError makes the first failed step the error of the run.
+func journeyStepError(data *MonitoringData) {
+	if data.ErrorDetails.HasError {
+		return
+	}
+	for i, step := range data.Steps {
+		if step.ErrorDetails.HasError {
+			data.ErrorDetails.HasError = true
+			data.ErrorDetails.ErrorType = step.ErrorDetails.ErrorType
+			data.ErrorDetails.ErrorMessage = fmt.Sprintf("Étape %d (%s): %s", i+1, step.Name, step.ErrorDetails.ErrorMessage)
+			return
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeDNSData(t *testing.T) {
+	tests := []struct {
+		name       string
+		dns        DNSDetails
+		hasError   bool
+		errorType  string
+		passed     *bool
+		missing    []string
+		unexpected []string
+	}{
+		{
+			name: "noerror without expectations",
+			dns:  DNSDetails{RecordType: "a", Rcode: "noerror", Answers: []string{"192.0.2.1"}},
+		},
+		{
+			name:      "nxdomain",
+			dns:       DNSDetails{RecordType: "A", Rcode: "nxdomain", Expected: []string{"192.0.2.1"}},
+			hasError:  true,
+			errorType: "dns_nxdomain",
+		},
+		{
+			name:    "any matches one answer",
+			dns:     DNSDetails{Rcode: "NOERROR", Answers: []string{"192.0.2.1"}, Expected: []string{"192.0.2.1", "192.0.2.2"}},
+			passed:  boolPtr(true),
+			missing: []string{"192.0.2.2"},
+		},
+		{
+			name:      "any matches none",
+			dns:       DNSDetails{Rcode: "NOERROR", Answers: []string{"192.0.2.9"}, Expected: []string{"192.0.2.1"}},
+			hasError:  true,
+			errorType: "dns_assertion_failed",
+			passed:    boolPtr(false),
+			missing:   []string{"192.0.2.1"},
+		},
+		{
+			name:   "all with names normalized",
+			dns:    DNSDetails{Rcode: "NOERROR", Answers: []string{"Mail.Example.com.", "10  mx.example.com."}, Expected: []string{"mail.example.com", "10 MX.example.com"}, ExpectMode: "all"},
+			passed: boolPtr(true),
+		},
+		{
+			name:      "all with one missing",
+			dns:       DNSDetails{Rcode: "NOERROR", Answers: []string{"192.0.2.1"}, Expected: []string{"192.0.2.1", "192.0.2.2"}, ExpectMode: "all"},
+			hasError:  true,
+			errorType: "dns_assertion_failed",
+			passed:    boolPtr(false),
+			missing:   []string{"192.0.2.2"},
+		},
+		{
+			name:       "exact with an extra answer",
+			dns:        DNSDetails{Rcode: "NOERROR", Answers: []string{"192.0.2.3", "192.0.2.1"}, Expected: []string{"192.0.2.1"}, ExpectMode: "exact"},
+			hasError:   true,
+			errorType:  "dns_assertion_failed",
+			passed:     boolPtr(false),
+			unexpected: []string{"192.0.2.3"},
+		},
+		{
+			name:   "exact match",
+			dns:    DNSDetails{Rcode: "NOERROR", Answers: []string{"192.0.2.2", "192.0.2.1"}, Expected: []string{"192.0.2.1", "192.0.2.2"}, ExpectMode: "exact"},
+			passed: boolPtr(true),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dns := tt.dns
+			dns.ResponseMs = 12
+			data := MonitoringData{CheckType: CheckTypeDNS, DNSDetails: &dns}
+			normalizeDNSData(&data)
+
+			if data.ErrorDetails.HasError != tt.hasError || data.ErrorDetails.ErrorType != tt.errorType {
+				t.Errorf("error = %v %q, want %v %q", data.ErrorDetails.HasError, data.ErrorDetails.ErrorType, tt.hasError, tt.errorType)
+			}
+			if (dns.AssertionPassed == nil) != (tt.passed == nil) || (tt.passed != nil && *dns.AssertionPassed != *tt.passed) {
+				t.Errorf("assertion_passed = %v, want %v", dns.AssertionPassed, tt.passed)
+			}
+			if !reflect.DeepEqual(dns.MissingExpected, tt.missing) {
+				t.Errorf("missing_expected = %v, want %v", dns.MissingExpected, tt.missing)
+			}
+			if !reflect.DeepEqual(dns.UnexpectedAnswers, tt.unexpected) {
+				t.Errorf("unexpected_answers = %v, want %v", dns.UnexpectedAnswers, tt.unexpected)
+			}
+			if data.TimingMetrics.TotalResponseMs != 12 || data.TimingMetrics.DNSLookupMs != 12 {
+				t.Errorf("timings = %+v, want the response time", data.TimingMetrics)
+			}
+		})
+	}
+}
+
+func TestNormalizeDNSDataUppercasesCodes(t *testing.T) {
+	data := MonitoringData{DNSDetails: &DNSDetails{RecordType: "aaaa", Rcode: "servfail"}}
+	normalizeDNSData(&data)
+	if data.DNSDetails.RecordType != "AAAA" || data.DNSDetails.Rcode != "SERVFAIL" {
+		t.Errorf("record_type, rcode = %q, %q", data.DNSDetails.RecordType, data.DNSDetails.Rcode)
+	}
+	if data.ErrorDetails.ErrorType != "dns_servfail" {
+		t.Errorf("error_type = %q, want dns_servfail", data.ErrorDetails.ErrorType)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
\ No newline at end of file
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Incident classifications.
+const (
+	IncidentScopeTarget  = "target"  // The target fails from most of the clients probing it
+	IncidentScopeProbe   = "probe"   // The probes fail while the target answers elsewhere, or fail every target
+	IncidentScopeUnknown = "unknown" // Not enough clients or targets to tell
+)
+
+// Matrix cell states.
+const (
+	CellStateOK       = "ok"
+	CellStateDegraded = "degraded" // Last sample good, failures earlier in the window
+	CellStateFailing  = "failing"
+)
+
+// correlationWindow is the recent period failures are correlated over.
+const correlationWindow = 5 * time.Minute
+
+// pairHealth is the recent health of a client against a target.
+type pairHealth struct {
+	Samples     int
+	Failures    int
+	LastFailed  bool
+	LastLatency float64
+	LastSeen    time.Time
+}
+
+// healthByPair holds the recent health of a tenant by client, then target.
+type healthByPair map[string]map[string]*pairHealth
+
+// recentPairHealth returns the health of every client × target pair of a
+// tenant over the samples received since a given time, maintenance excluded.
+// Failed and abnormally slow samples count as failures.
+func (s *Server) recentPairHealth(tenantID string, since time.Time) (healthByPair, error) {
+	rows, err := s.db.Query(`
+		SELECT client_id, target_url, timestamp, success, anomalous, latency
+		FROM client_history
+		WHERE tenant_id = ? AND timestamp > ? AND maintenance = 0 AND target_url != ''
+		ORDER BY timestamp`,
+		tenantID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	health := make(healthByPair)
+	for rows.Next() {
+		var clientID, targetURL string
+		var ts time.Time
+		var success, anomalous bool
+		var latency float64
+		if err := rows.Scan(&clientID, &targetURL, &ts, &success, &anomalous, &latency); err != nil {
+			log.Printf("Erreur de scan de l'historique du tenant %s: %v", tenantID, err)
+			continue
+		}
+		if health[clientID] == nil {
+			health[clientID] = make(map[string]*pairHealth)
+		}
+		h := health[clientID][targetURL]
+		if h == nil {
+			h = &pairHealth{}
+			health[clientID][targetURL] = h
+		}
+		failed := !success || anomalous
+		h.Samples++
+		if failed {
+			h.Failures++
+		}
+		h.LastFailed, h.LastLatency, h.LastSeen = failed, latency, ts
+	}
+	return health, nil
+}
+
+// targetDown reports whether a target currently fails from at least two
+// clients, and from most of the clients probing it.
+func (health healthByPair) targetDown(targetURL string) bool {
+	probing, failing := 0, 0
+	for _, targets := range health {
+		if h, ok := targets[targetURL]; ok {
+			probing++
+			if h.LastFailed {
+				failing++
+			}
+		}
+	}
+	return failing >= 2 && 2*failing >= probing
+}
+
+// probeDown reports whether a client currently fails against all of its
+// targets while the problem cannot be the targets': it probes several of
+// them, or another client reaches one of them fine.
+func (health healthByPair) probeDown(clientID string) bool {
+	targets := health[clientID]
+	if len(targets) == 0 {
+		return false
+	}
+	for _, h := range targets {
+		if !h.LastFailed {
+			return false
+		}
+	}
+	if len(targets) >= 2 {
+		return true
+	}
+	for other, otherTargets := range health {
+		if other == clientID {
+			continue
+		}
+		for targetURL := range targets {
+			if h, ok := otherTargets[targetURL]; ok && !h.LastFailed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// classifyIncident tells whether an incident on a target, with the given
+// failing clients, is an outage of the target or a problem of the probes.
+func (s *Server) classifyIncident(tenantID, targetURL string, failing []string) (string, error) {
+	health, err := s.recentPairHealth(tenantID, time.Now().Add(-correlationWindow))
+	if err != nil {
+		return IncidentScopeUnknown, err
+	}
+	return health.classify(targetURL, failing), nil
+}
+
+// classify returns the scope of an incident on a target with the given
+// failing clients: the target when it is down, the probes when one of them
+// is, unknown otherwise.
+func (health healthByPair) classify(targetURL string, failing []string) string {
+	if health.targetDown(targetURL) {
+		return IncidentScopeTarget
+	}
+	for _, clientID := range failing {
+		if health.probeDown(clientID) {
+			return IncidentScopeProbe
+		}
+	}
+	return IncidentScopeUnknown
+}
+
+// healthMatrix returns the state of every client × target pair of a tenant
+// over the samples received since a given time.
+func (s *Server) healthMatrix(tenantID string, since time.Time) (HealthMatrix, error) {
+	m := HealthMatrix{Since: since, Clients: []string{}, Targets: []string{}, Cells: []MatrixCell{},
+		DownTargets: []string{}, DownProbes: []string{}}
+	health, err := s.recentPairHealth(tenantID, since)
+	if err != nil {
+		return m, err
+	}
+
+	targets := make(map[string]bool)
+	for clientID, pairs := range health {
+		m.Clients = append(m.Clients, clientID)
+		for targetURL, h := range pairs {
+			targets[targetURL] = true
+			cell := MatrixCell{
+				ClientID:      clientID,
+				TargetURL:     targetURL,
+				State:         CellStateOK,
+				Samples:       h.Samples,
+				Failures:      h.Failures,
+				LastLatencyMs: h.LastLatency,
+				LastSeen:      h.LastSeen,
+			}
+			if h.LastFailed {
+				cell.State = CellStateFailing
+			} else if h.Failures > 0 {
+				cell.State = CellStateDegraded
+			}
+			m.Cells = append(m.Cells, cell)
+		}
+		if health.probeDown(clientID) {
+			m.DownProbes = append(m.DownProbes, clientID)
+		}
+	}
+	for targetURL := range targets {
+		m.Targets = append(m.Targets, targetURL)
+		if health.targetDown(targetURL) {
+			m.DownTargets = append(m.DownTargets, targetURL)
+		}
+	}
+	sort.Strings(m.Clients)
+	sort.Strings(m.Targets)
+	sort.Strings(m.DownTargets)
+	sort.Strings(m.DownProbes)
+	sort.Slice(m.Cells, func(i, j int) bool {
+		if m.Cells[i].ClientID != m.Cells[j].ClientID {
+			return m.Cells[i].ClientID < m.Cells[j].ClientID
+		}
+		return m.Cells[i].TargetURL < m.Cells[j].TargetURL
+	})
+	return m, nil
+}
+
+// matrixPeriod reads the ?minutes= period of the matrix, 15 minutes by default.
+func matrixPeriod(r *http.Request) int {
+	if m, err := strconv.Atoi(r.URL.Query().Get("minutes")); err == nil && m > 0 && m <= 24*60 {
+		return m
+	}
+	return 15
+}
+
+// HandleMatrix returns the client × target health matrix of the tenant
+// (GET ?minutes=).
+func (s *Server) HandleMatrix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	minutes := matrixPeriod(r)
+	m, err := s.healthMatrix(s.requestTenant(r), time.Now().Add(-time.Duration(minutes)*time.Minute))
+	if err != nil {
+		log.Printf("Erreur de calcul de la matrice de santé: %v", err)
+		http.Error(w, "Erreur de calcul de la matrice", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// HandleMatrixPage renders the client × target health matrix of the tenant.
+func (s *Server) HandleMatrixPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID := s.requestTenant(r)
+	minutes := matrixPeriod(r)
+	m, err := s.healthMatrix(tenantID, time.Now().Add(-time.Duration(minutes)*time.Minute))
+	if err != nil {
+		log.Printf("Erreur de calcul de la matrice de santé: %v", err)
+		http.Error(w, "Erreur de calcul de la matrice", http.StatusInternalServerError)
+		return
+	}
+
+	// One row per client with a cell per target, nil where it has no samples
+	type matrixRow struct {
+		ClientID  string
+		ProbeDown bool
+		Cells     []*MatrixCell
+	}
+	type matrixColumn struct {
+		TargetURL  string
+		TargetDown bool
+	}
+	contains := func(list []string, v string) bool {
+		i := sort.SearchStrings(list, v)
+		return i < len(list) && list[i] == v
+	}
+	var columns []matrixColumn
+	index := make(map[string]int)
+	for i, targetURL := range m.Targets {
+		columns = append(columns, matrixColumn{TargetURL: targetURL, TargetDown: contains(m.DownTargets, targetURL)})
+		index[targetURL] = i
+	}
+	var rows []matrixRow
+	for i := range m.Cells {
+		cell := &m.Cells[i]
+		if len(rows) == 0 || rows[len(rows)-1].ClientID != cell.ClientID {
+			rows = append(rows, matrixRow{
+				ClientID:  cell.ClientID,
+				ProbeDown: contains(m.DownProbes, cell.ClientID),
+				Cells:     make([]*MatrixCell, len(m.Targets)),
+			})
+		}
+		rows[len(rows)-1].Cells[index[cell.TargetURL]] = cell
+	}
+
+	tmpl, err := template.New("matrix.html").
+		Funcs(template.FuncMap{
+			"formatTime": func(t time.Time) string {
+				return t.Format("02/01/2006 15:04:05")
+			},
+		}).
+		ParseFiles("templates/matrix.html")
+	if err != nil {
+		log.Printf("Erreur de chargement du template de la matrice: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := struct {
+		CurrentUser   *User
+		CurrentTenant string
+		Minutes       int
+		Matrix        HealthMatrix
+		Columns       []matrixColumn
+		Rows          []matrixRow
+	}{
+		CurrentUser:   userFromContext(r.Context()),
+		CurrentTenant: tenantID,
+		Minutes:       minutes,
+		Matrix:        m,
+		Columns:       columns,
+		Rows:          rows,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := tmpl.Execute(w, pageData); err != nil {
+		log.Printf("Erreur lors de l'exécution du template de la matrice: %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+// pairs builds a healthByPair from "client target" keys to whether the last
+// sample of the pair failed.
+func pairs(lastFailed map[string]bool) healthByPair {
+	health := make(healthByPair)
+	for key, failed := range lastFailed {
+		fields := strings.Fields(key)
+		clientID, targetURL := fields[0], fields[1]
+		if health[clientID] == nil {
+			health[clientID] = make(map[string]*pairHealth)
+		}
+		h := &pairHealth{Samples: 1, LastFailed: failed}
+		if failed {
+			h.Failures = 1
+		}
+		health[clientID][targetURL] = h
+	}
+	return health
+}
+
+func TestIncidentClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		health     healthByPair
+		target     string
+		failing    []string
+		targetDown bool
+		probeDown  map[string]bool
+		scope      string
+	}{
+		{
+			name:    "one client failing its only target",
+			health:  pairs(map[string]bool{"c1 a": true}),
+			target:  "a",
+			failing: []string{"c1"},
+			scope:   IncidentScopeUnknown,
+		},
+		{
+			name:    "one client failing one of two targets",
+			health:  pairs(map[string]bool{"c1 a": true, "c1 b": false}),
+			target:  "a",
+			failing: []string{"c1"},
+			scope:   IncidentScopeUnknown,
+		},
+		{
+			name:       "target failing from most clients",
+			health:     pairs(map[string]bool{"c1 a": true, "c2 a": true, "c3 a": false, "c3 b": false}),
+			target:     "a",
+			failing:    []string{"c1", "c2"},
+			targetDown: true,
+			probeDown:  map[string]bool{"c1": true, "c2": true},
+			scope:      IncidentScopeTarget,
+		},
+		{
+			name:      "target reached by most clients",
+			health:    pairs(map[string]bool{"c1 a": true, "c2 a": true, "c3 a": false, "c4 a": false, "c5 a": false}),
+			target:    "a",
+			failing:   []string{"c1", "c2"},
+			probeDown: map[string]bool{"c1": true, "c2": true},
+			scope:     IncidentScopeProbe,
+		},
+		{
+			name:      "probe failing every target",
+			health:    pairs(map[string]bool{"c1 a": true, "c1 b": true, "c2 b": true}),
+			target:    "a",
+			failing:   []string{"c1"},
+			probeDown: map[string]bool{"c1": true},
+			scope:     IncidentScopeProbe,
+		},
+		{
+			name:      "probe failing while another client reaches the target",
+			health:    pairs(map[string]bool{"c1 a": true, "c2 a": false}),
+			target:    "a",
+			failing:   []string{"c1"},
+			probeDown: map[string]bool{"c1": true},
+			scope:     IncidentScopeProbe,
+		},
+		{
+			name:       "target down wins over a probe down",
+			health:     pairs(map[string]bool{"c1 a": true, "c1 b": true, "c2 a": true}),
+			target:     "a",
+			failing:    []string{"c1", "c2"},
+			targetDown: true,
+			probeDown:  map[string]bool{"c1": true},
+			scope:      IncidentScopeTarget,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.health.targetDown(tt.target); got != tt.targetDown {
+			t.Errorf("%s: targetDown(%s) = %v, want %v", tt.name, tt.target, got, tt.targetDown)
+		}
+		for clientID := range tt.health {
+			if got := tt.health.probeDown(clientID); got != tt.probeDown[clientID] {
+				t.Errorf("%s: probeDown(%s) = %v, want %v", tt.name, clientID, got, tt.probeDown[clientID])
+			}
+		}
+		if got := tt.health.classify(tt.target, tt.failing); got != tt.scope {
+			t.Errorf("%s: classify = %s, want %s", tt.name, got, tt.scope)
+		}
+	}
+
+	if pairs(nil).probeDown("c1") {
+		t.Error("probeDown of a client without samples")
+	}
+}
\ No newline at end of file
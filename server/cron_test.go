@@ -0,0 +1,192 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	bits := func(values ...int) uint64 {
+		var set uint64
+		for _, v := range values {
+			set |= 1 << uint(v)
+		}
+		return set
+	}
+
+	tests := []struct {
+		field    string
+		min, max int
+		want     uint64
+		wantErr  bool
+	}{
+		{field: "*", min: 0, max: 6, want: bits(0, 1, 2, 3, 4, 5, 6)},
+		{field: "5", min: 0, max: 59, want: bits(5)},
+		{field: "1,15,31", min: 1, max: 31, want: bits(1, 15, 31)},
+		{field: "22-23", min: 0, max: 23, want: bits(22, 23)},
+		{field: "*/15", min: 0, max: 59, want: bits(0, 15, 30, 45)},
+		{field: "5/20", min: 0, max: 59, want: bits(5, 25, 45)},
+		{field: "1-10/3", min: 1, max: 31, want: bits(1, 4, 7, 10)},
+		{field: "1-2,*/6", min: 0, max: 23, want: bits(0, 1, 2, 6, 12, 18)},
+		{field: "60", min: 0, max: 59, wantErr: true},
+		{field: "0", min: 1, max: 31, wantErr: true},
+		{field: "10-5", min: 0, max: 59, wantErr: true},
+		{field: "1-", min: 0, max: 59, wantErr: true},
+		{field: "*/0", min: 0, max: 59, wantErr: true},
+		{field: "*/x", min: 0, max: 59, wantErr: true},
+		{field: "mon", min: 0, max: 7, wantErr: true},
+		{field: "", min: 0, max: 59, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCronField(tt.field, tt.min, tt.max)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCronField(%q) error = %v, wantErr %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseCronField(%q) = %b, want %b", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "* * * * * *", "* 24 * * *", "* * * 13 *", "* * * * 8"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) accepted", expr)
+		}
+	}
+}
+
+func TestCronMatchesDay(t *testing.T) {
+	sunday := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	monday := sunday.AddDate(0, 0, 1)
+	first := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC) // Also a Sunday
+	second := first.AddDate(0, 0, 1)
+
+	tests := []struct {
+		expr string
+		day  time.Time
+		want bool
+	}{
+		{"0 0 * * *", monday, true},
+		{"0 0 * * 0", sunday, true},
+		{"0 0 * * 7", sunday, true},
+		{"0 0 * * 7", monday, false},
+		{"0 0 * * 1-5", monday, true},
+		{"0 0 * * 1-5", sunday, false},
+		{"0 0 1 * *", first, true},
+		{"0 0 1 * *", sunday, false},
+		// Day of month or day of week when both are restricted
+		{"0 0 1 * 1", first, true},
+		{"0 0 1 * 1", monday, true},
+		{"0 0 1 * 1", second, true},
+		{"0 0 1 * 1", sunday, false},
+		{"0 0 15 * 3", monday, false},
+		{"0 0 * 10 *", sunday, true},
+		{"0 0 * 10 *", first, false},
+	}
+
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.matchesDay(tt.day); got != tt.want {
+			t.Errorf("%q matchesDay(%s) = %v, want %v", tt.expr, tt.day.Format("Mon 2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestCronNextAndPrev(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	week := 7 * 24 * time.Hour
+
+	tests := []struct {
+		expr       string
+		from       time.Time
+		next, prev time.Time
+	}{
+		{"* * * * *", at(18, 10, 30).Add(20 * time.Second), at(18, 10, 31), at(18, 10, 30)},
+		{"*/15 * * * *", at(18, 10, 31), at(18, 10, 45), at(18, 10, 30)},
+		{"0 2 * * *", at(18, 2, 0), at(18, 2, 0), at(18, 2, 0)},
+		{"0 2 * * *", at(18, 3, 0), at(19, 2, 0), at(18, 2, 0)},
+		{"30 22-23 * * *", at(18, 23, 45), at(19, 22, 30), at(18, 23, 30)},
+		{"0 2 * * 1-5", at(17, 12, 0), at(19, 2, 0), at(16, 2, 0)},
+		{"0 0 * * 7", at(20, 12, 0), at(25, 0, 0), at(18, 0, 0)},
+		{"0 3 1 * 5", at(20, 0, 0), at(23, 3, 0), at(16, 3, 0)},
+	}
+
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got, ok := c.next(tt.from, tt.from.Add(week)); !ok || !got.Equal(tt.next) {
+			t.Errorf("%q next(%s) = %s, %v, want %s", tt.expr, tt.from, got, ok, tt.next)
+		}
+		if got, ok := c.prev(tt.from, tt.from.Add(-week)); !ok || !got.Equal(tt.prev) {
+			t.Errorf("%q prev(%s) = %s, %v, want %s", tt.expr, tt.from, got, ok, tt.prev)
+		}
+	}
+
+	c, _ := parseCron("0 2 * * *")
+	if got, ok := c.next(at(18, 3, 0), at(19, 2, 0)); ok {
+		t.Errorf("next past its limit = %s", got)
+	}
+	if got, ok := c.prev(at(18, 1, 0), at(17, 2, 1)); ok {
+		t.Errorf("prev before its earliest time = %s", got)
+	}
+}
+
+func TestMaintenanceWindowActiveAt(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local)
+	}
+	nightly := MaintenanceWindow{Cron: "0 2 * * *", DurationMinutes: 60}
+	bounded := nightly
+	bounded.Start, bounded.End = at(18, 0, 0), at(19, 0, 0)
+
+	tests := []struct {
+		name  string
+		mw    MaintenanceWindow
+		at    time.Time
+		start time.Time
+		want  bool
+	}{
+		{"one-off inside", MaintenanceWindow{Start: at(18, 2, 0), End: at(18, 3, 0)}, at(18, 2, 30), at(18, 2, 0), true},
+		{"one-off at its end", MaintenanceWindow{Start: at(18, 2, 0), End: at(18, 3, 0)}, at(18, 3, 0), time.Time{}, false},
+		{"recurring at its start", nightly, at(18, 2, 0), at(18, 2, 0), true},
+		{"recurring inside", nightly, at(18, 2, 59), at(18, 2, 0), true},
+		{"recurring after its duration", nightly, at(18, 3, 0), time.Time{}, false},
+		{"recurring before its start", nightly, at(18, 1, 59), time.Time{}, false},
+		{"every minute", MaintenanceWindow{Cron: "* * * * *", DurationMinutes: 5}, at(18, 10, 7), at(18, 10, 7), true},
+		{"within its bounds", bounded, at(18, 2, 30), at(18, 2, 0), true},
+		{"after its bounds", bounded, at(19, 2, 30), time.Time{}, false},
+		{"before its bounds", bounded, at(17, 2, 30), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.mw.activeAt(tt.at)
+		if ok != tt.want {
+			t.Errorf("%s: activeAt = %v, want %v", tt.name, ok, tt.want)
+			continue
+		}
+		if ok && !got.Start.Equal(tt.start) {
+			t.Errorf("%s: occurrence starts at %s, want %s", tt.name, got.Start, tt.start)
+		}
+	}
+}
+
+func TestMaintenanceWindowOccurrencesLimit(t *testing.T) {
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local)
+	mw := MaintenanceWindow{Cron: "* * * * *", DurationMinutes: 1}
+	if got := mw.occurrences(from, from.Add(24*time.Hour), 10); len(got) != 10 {
+		t.Errorf("occurrences with a limit of 10 = %d", len(got))
+	}
+	if got := mw.occurrences(from, from.Add(time.Hour), 0); len(got) != 60 {
+		t.Errorf("occurrences over an hour = %d, want 60", len(got))
+	}
+}
\ No newline at end of file
@@ -41,40 +41,360 @@ func initDatabase(dataSourceName string) (*sql.DB, error) {
 
 	CREATE INDEX IF NOT EXISTS idx_client_history_client_time
 	ON client_history(client_id, timestamp DESC);
+
+	CREATE TABLE IF NOT EXISTS probe_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id TEXT NOT NULL,
+		name TEXT,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_probe_tokens_client
+	ON probe_tokens(client_id);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL,
+		created_at DATETIME,
+		disabled BOOLEAN NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME,
+		expires_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME,
+		actor TEXT,
+		action TEXT,
+		target TEXT,
+		details TEXT,
+		remote_addr TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS tenants (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		retention_days INTEGER NOT NULL DEFAULT 7,
+		max_clients INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS target_certificates (
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		target_url TEXT NOT NULL,
+		chain_fingerprint TEXT NOT NULL,
+		not_after DATETIME,
+		tls_version TEXT,
+		cipher_suite TEXT,
+		chain TEXT,
+		first_seen DATETIME,
+		last_seen DATETIME,
+		previous_chain TEXT,
+		changed_at DATETIME,
+		unexpected_change BOOLEAN NOT NULL DEFAULT 0,
+		PRIMARY KEY (tenant_id, client_id, target_url)
+	);
+
+	CREATE TABLE IF NOT EXISTS alert_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		client_id TEXT NOT NULL DEFAULT '',
+		params TEXT,
+		enabled BOOLEAN NOT NULL DEFAULT 1,
+		created_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		rule_id INTEGER NOT NULL,
+		client_id TEXT NOT NULL,
+		target TEXT NOT NULL,
+		message TEXT,
+		state TEXT NOT NULL,
+		started_at DATETIME,
+		last_seen_at DATETIME,
+		resolved_at DATETIME,
+		FOREIGN KEY(rule_id) REFERENCES alert_rules(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_alerts_rule_state
+	ON alerts(rule_id, state);
+
+	CREATE TABLE IF NOT EXISTS path_traces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		timestamp DATETIME,
+		sample_timestamp TEXT,
+		success BOOLEAN,
+		destination_ip TEXT,
+		trace TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_path_traces_client_time
+	ON path_traces(tenant_id, client_id, timestamp);
+
+	CREATE TABLE IF NOT EXISTS probe_heartbeats (
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		received_at DATETIME,
+		data TEXT,
+		PRIMARY KEY (tenant_id, client_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS checks (
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		definition TEXT NOT NULL,
+		updated_at DATETIME,
+		PRIMARY KEY (tenant_id, client_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL DEFAULT '',
+		start_time DATETIME NOT NULL,
+		end_time DATETIME NOT NULL,
+		reason TEXT,
+		created_by TEXT,
+		created_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS slos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		objective REAL NOT NULL,
+		latency_ms REAL NOT NULL DEFAULT 0,
+		window_days INTEGER NOT NULL DEFAULT 28,
+		created_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS silences (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL DEFAULT '',
+		rule_id INTEGER NOT NULL DEFAULT 0,
+		reason TEXT,
+		created_by TEXT,
+		created_at DATETIME,
+		expires_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS incidents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		target_url TEXT NOT NULL,
+		state TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		last_bad_at DATETIME NOT NULL,
+		ended_at DATETIME,
+		samples INTEGER NOT NULL DEFAULT 0,
+		peak_latency REAL NOT NULL DEFAULT 0,
+		error_counts TEXT,
+		clients TEXT,
+		root_cause TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_incidents_target_state
+	ON incidents(tenant_id, target_url, state);
+
+	CREATE TABLE IF NOT EXISTS incident_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		incident_id INTEGER NOT NULL,
+		author TEXT,
+		note TEXT NOT NULL,
+		created_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS phase_shifts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		phase TEXT NOT NULL,
+		changed_at DATETIME NOT NULL,
+		detected_at DATETIME NOT NULL,
+		before_ms REAL NOT NULL,
+		after_ms REAL NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_phase_shifts_client
+	ON phase_shifts(tenant_id, client_id, changed_at);
+
+	CREATE TABLE IF NOT EXISTS sites (
+		tenant_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		region TEXT NOT NULL DEFAULT '',
+		latitude REAL NOT NULL DEFAULT 0,
+		longitude REAL NOT NULL DEFAULT 0,
+		subnets TEXT,
+		PRIMARY KEY (tenant_id, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS remote_ips (
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		target_url TEXT NOT NULL,
+		remote_ip TEXT NOT NULL,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL,
+		samples INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (tenant_id, client_id, target_url, remote_ip)
+	);
+
+	CREATE TABLE IF NOT EXISTS ip_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		target_url TEXT NOT NULL,
+		previous_ip TEXT NOT NULL,
+		new_ip TEXT NOT NULL,
+		changed_at DATETIME NOT NULL,
+		unexpected BOOLEAN NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ip_changes_client
+	ON ip_changes(tenant_id, client_id, changed_at);
 	`
 
-	_, err = db.Exec(schema)
+	if _, err = db.Exec(schema); err != nil {
+		return db, err
+	}
+
+	// Columns added after the initial schema, applied to existing databases
+	migrations := []struct{ table, column, definition string }{
+		{"clients", "tenant_id", "TEXT NOT NULL DEFAULT '" + defaultTenantID + "'"},
+		{"client_history", "tenant_id", "TEXT NOT NULL DEFAULT '" + defaultTenantID + "'"},
+		{"probe_tokens", "tenant_id", "TEXT NOT NULL DEFAULT '" + defaultTenantID + "'"},
+		{"users", "tenant_id", "TEXT NOT NULL DEFAULT ''"}, // '' = all tenants
+		{"audit_log", "tenant_id", "TEXT NOT NULL DEFAULT ''"},
+		{"client_history", "check_type", "TEXT NOT NULL DEFAULT 'http'"},
+		{"clients", "declared_interval", "INTEGER NOT NULL DEFAULT 0"},
+		{"tenants", "gap_policy", "TEXT NOT NULL DEFAULT '" + GapPolicyIgnore + "'"},
+		{"client_history", "maintenance", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"maintenance_windows", "target_url", "TEXT NOT NULL DEFAULT ''"},
+		{"maintenance_windows", "tag", "TEXT NOT NULL DEFAULT ''"},
+		{"maintenance_windows", "cron", "TEXT NOT NULL DEFAULT ''"},
+		{"maintenance_windows", "duration_minutes", "INTEGER NOT NULL DEFAULT 0"},
+		{"client_history", "anomalous", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"client_history", "anomaly_score", "REAL NOT NULL DEFAULT 0"},
+		{"client_history", "target_url", "TEXT NOT NULL DEFAULT ''"},
+		{"incidents", "classification", "TEXT NOT NULL DEFAULT '" + IncidentScopeUnknown + "'"},
+		{"clients", "site", "TEXT NOT NULL DEFAULT ''"},
+		{"client_history", "protocol", "TEXT NOT NULL DEFAULT ''"},
+		{"client_history", "connection_reused", "BOOLEAN NOT NULL DEFAULT 0"},
+	}
+	for _, m := range migrations {
+		if err := addColumnIfMissing(db, m.table, m.column, m.definition); err != nil {
+			return db, err
+		}
+	}
+
+	indexes := `
+	CREATE INDEX IF NOT EXISTS idx_clients_tenant
+	ON clients(tenant_id);
+
+	CREATE INDEX IF NOT EXISTS idx_client_history_tenant_time
+	ON client_history(tenant_id, timestamp);
+	`
+	if _, err = db.Exec(indexes); err != nil {
+		return db, err
+	}
+
+	_, err = db.Exec(`
+		INSERT OR IGNORE INTO tenants (id, name, created_at)
+		VALUES (?, ?, ?)`,
+		defaultTenantID, "Par défaut", time.Now())
 	return db, err
 }
 
-// storeMonitoringData stores monitoring data into the database.
-func (s *Server) storeMonitoringData(data MonitoringData) error {
+// addColumnIfMissing adds a column to an existing table unless it is already there.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+// storeMonitoringData stores monitoring data into the database for the given tenant.
+func (s *Server) storeMonitoringData(tenantID string, data MonitoringData) error {
 	// Serialize the complete data
 	jsonData, _ := json.Marshal(data)
 
-	// Update client's last seen and last data
-	_, err := s.db.Exec(`
-		INSERT OR REPLACE INTO clients (id, name, target_url, last_seen, last_data)
-		VALUES (?, ?, ?, ?, ?)`,
-		data.ClientID, data.ClientID, data.TargetURL, time.Now(), string(jsonData))
+	// Update client's last seen and last data; a client never changes tenant
+	res, err := s.db.Exec(`
+		INSERT INTO clients (id, name, target_url, last_seen, last_data, tenant_id, declared_interval, site)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			target_url = excluded.target_url,
+			last_seen = excluded.last_seen,
+			last_data = excluded.last_data,
+			declared_interval = CASE WHEN excluded.declared_interval > 0 THEN excluded.declared_interval ELSE clients.declared_interval END,
+			site = CASE WHEN excluded.site != '' THEN excluded.site ELSE clients.site END
+		WHERE clients.tenant_id = excluded.tenant_id`,
+		data.ClientID, data.ClientID, data.TargetURL, time.Now(), string(jsonData), tenantID, data.IntervalSeconds, data.Site)
 
 	if err != nil {
 		return err
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("le client %s appartient à un autre tenant", data.ClientID)
+	}
 
 	// Add to history
 	success := !data.ErrorDetails.HasError
 	latency := data.TimingMetrics.TotalResponseMs
-	statusCode := data.ResponseDetails.StatusCode
+	var statusCode interface{} // NULL for checks without an HTTP status
+	if data.IsHTTPCheck() {
+		statusCode = data.ResponseDetails.StatusCode
+	}
 	errorType := ""
 	if data.ErrorDetails.HasError {
 		errorType = data.ErrorDetails.ErrorType
 	}
 
 	_, err = s.db.Exec(`
-		INSERT INTO client_history (client_id, timestamp, success, latency, status_code, error_type, data)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		data.ClientID, time.Now(), success, latency, statusCode, errorType, string(jsonData))
+		INSERT INTO client_history (client_id, timestamp, success, latency, status_code, error_type, data, tenant_id, check_type, maintenance, anomalous, anomaly_score, target_url, protocol, connection_reused)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		data.ClientID, time.Now(), success, latency, statusCode, errorType, string(jsonData), tenantID, data.CheckType, data.Maintenance, data.Anomalous, data.AnomalyScore, data.TargetURL,
+		data.NetworkInfo.ProtocolVersion, data.NetworkInfo.ConnectionReused)
 
 	return err
 }
@@ -87,8 +407,8 @@ func (s *Server) getFilteredClientHistory(options HistoryFilterOptions) ([]Monit
 	query := `
 		SELECT data
 		FROM client_history
-		WHERE client_id = ? AND timestamp > ?`
-	args = append(args, options.ClientID, time.Now().Add(-options.Duration))
+		WHERE tenant_id = ? AND client_id = ? AND timestamp > ?`
+	args = append(args, options.TenantID, options.ClientID, time.Now().Add(-options.Duration))
 
 	if options.StatusFilter == "success" {
 		query += ` AND success = 1`
@@ -149,12 +469,14 @@ func (s *Server) getFilteredClientHistory(options HistoryFilterOptions) ([]Monit
 	return history, nil
 }
 
-// getClientStatuses retrieves the current status of all clients.
-func (s *Server) getClientStatuses() ([]ClientStatus, error) {
+// getClientStatuses retrieves the current status of all clients of a tenant.
+func (s *Server) getClientStatuses(tenantID string) ([]ClientStatus, error) {
 	rows, err := s.db.Query(`
-		SELECT id, name, target_url, last_seen, last_data
-		FROM clients
-		ORDER BY last_seen DESC`)
+		SELECT c.id, c.name, c.target_url, c.last_seen, c.last_data, c.declared_interval, c.site, h.data
+		FROM clients c
+		LEFT JOIN probe_heartbeats h ON h.tenant_id = c.tenant_id AND h.client_id = c.id
+		WHERE c.tenant_id = ?
+		ORDER BY c.last_seen DESC`, tenantID)
 
 	if err != nil {
 		return nil, err
@@ -164,28 +486,75 @@ func (s *Server) getClientStatuses() ([]ClientStatus, error) {
 	var clients []ClientStatus
 	now := time.Now()
 
+	// Intervals set in check definitions take precedence over the probes'
+	checkIntervals := make(map[string]int)
+	tags := make(map[string][]string)
+	if defs, err := s.listCheckDefinitions(tenantID); err == nil {
+		for _, def := range defs {
+			checkIntervals[def.ClientID] = def.Interval
+			tags[def.ClientID] = def.Tags
+		}
+	}
+	maintenance, err := s.activeMaintenance(tenantID, now)
+	if err != nil {
+		log.Printf("Erreur de récupération des fenêtres de maintenance: %v", err)
+	}
+	silences, err := s.listSilences(tenantID, now)
+	if err != nil {
+		log.Printf("Erreur de récupération des silences: %v", err)
+	}
+	gapPolicy := GapPolicyIgnore
+	if t, err := s.getTenant(tenantID); err == nil {
+		gapPolicy = t.GapPolicy
+	}
+
 	for rows.Next() {
-		var id, name, targetURL, lastDataStr string
-		var lastSeen time.Time
+		var id, name, targetURL, site string
+		var lastSeen sql.NullTime // Unset for probes that only sent heartbeats so far
+		var declaredInterval int
+		var lastDataStr, heartbeat sql.NullString
 
-		err := rows.Scan(&id, &name, &targetURL, &lastSeen, &lastDataStr)
+		err := rows.Scan(&id, &name, &targetURL, &lastSeen, &lastDataStr, &declaredInterval, &site, &heartbeat)
 		if err != nil {
 			log.Printf("Erreur de scan de la ligne client: %v", err)
 			continue
 		}
 
 		var lastData MonitoringData
-		json.Unmarshal([]byte(lastDataStr), &lastData) // Errors here are non-fatal, as we have fallback data
+		json.Unmarshal([]byte(lastDataStr.String), &lastData) // Errors here are non-fatal, as we have fallback data
+		if lastData.CheckType == "" {
+			lastData.CheckType = CheckTypeHTTP
+		}
+
+		var probe *ProbeHeartbeat
+		if heartbeat.Valid {
+			probe = &ProbeHeartbeat{}
+			if err := json.Unmarshal([]byte(heartbeat.String), probe); err != nil {
+				log.Printf("Erreur de décodage du heartbeat du client %s: %v", id, err)
+				probe = nil
+			}
+		}
 
-		successRate := s.calculateSuccessRate(id)
-		lastError, lastErrorTime := s.getLastError(id)
+		interval, intervalSource := s.expectedInterval(tenantID, id, checkIntervals[id], declaredInterval)
+		state, missed := ClientStateOffline, 0
+		if lastSeen.Valid {
+			state, missed = clientState(now.Sub(lastSeen.Time), interval)
+		}
+
+		successRate := s.calculateSuccessRate(tenantID, id, interval, gapPolicy)
+		lastError, lastErrorTime := s.getLastError(tenantID, id)
 
 		client := ClientStatus{
 			ID:              id,
 			Name:            name,
+			CheckType:       lastData.CheckType,
 			TargetURL:       targetURL,
-			LastSeen:        lastSeen,
-			IsOnline:        now.Sub(lastSeen) < 60*time.Second, // Offline after 1 minute
+			LastSeen:        lastSeen.Time,
+			IsOnline:        state == ClientStateOnline || state == ClientStateLate,
+			State:           state,
+			Interval:        interval,
+			IntervalSource:  intervalSource,
+			MissedReports:   missed,
 			LastLatency:     lastData.TimingMetrics.TotalResponseMs,
 			LastStatusCode:  lastData.ResponseDetails.StatusCode,
 			SuccessRate:     successRate,
@@ -193,6 +562,27 @@ func (s *Server) getClientStatuses() ([]ClientStatus, error) {
 			LastErrorTime:   lastErrorTime,
 			TimingBreakdown: lastData.TimingMetrics,
 			NetworkInfo:     lastData.NetworkInfo,
+			TCPDetails:      lastData.TCPDetails,
+			DNSDetails:      lastData.DNSDetails,
+			GRPCDetails:     lastData.GRPCDetails,
+			Steps:           lastData.Steps,
+			Probe:           probe,
+			ProbeOnline:     isProbeOnline(probe, now),
+			Tags:            tags[id],
+			Site:            site,
+		}
+		for _, mw := range maintenance {
+			if mw.appliesTo(id, targetURL, client.Tags) {
+				client.InMaintenance = true
+				break
+			}
+		}
+		for _, sl := range silences {
+			// Only silences covering every rule of the client are shown
+			if sl.matches(id, 0) && (client.SilencedUntil == nil || sl.ExpiresAt.After(*client.SilencedUntil)) {
+				expires := sl.ExpiresAt
+				client.SilencedUntil = &expires
+			}
 		}
 
 		clients = append(clients, client)
@@ -201,17 +591,29 @@ func (s *Server) getClientStatuses() ([]ClientStatus, error) {
 	return clients, nil
 }
 
-// calculateSuccessRate calculates the success rate for a client over the last 24 hours.
-func (s *Server) calculateSuccessRate(clientID string) float64 {
+// calculateSuccessRate calculates the success rate for a client over the last
+// 24 hours, leaving out samples received during maintenance. Under the down
+// gap policy, samples missed in gaps count as failures.
+func (s *Server) calculateSuccessRate(tenantID, clientID string, interval int, gapPolicy string) float64 {
 	var total, success int
 
 	err := s.db.QueryRow(`
 		SELECT COUNT(*), SUM(CASE WHEN success THEN 1 ELSE 0 END)
 		FROM client_history
-		WHERE client_id = ? AND timestamp > datetime('now', '-24 hours')`,
-		clientID).Scan(&total, &success)
+		WHERE tenant_id = ? AND client_id = ? AND timestamp > datetime('now', '-24 hours') AND maintenance = 0`,
+		tenantID, clientID).Scan(&total, &success)
 
-	if err != nil || total == 0 {
+	if err != nil {
+		return 0.0
+	}
+	if gapPolicy == GapPolicyDown {
+		if gaps, _, err := s.findGaps(tenantID, clientID, interval, 24*time.Hour); err == nil {
+			for _, g := range gaps {
+				total += g.MissedSamples
+			}
+		}
+	}
+	if total == 0 {
 		return 0.0
 	}
 
@@ -219,16 +621,16 @@ func (s *Server) calculateSuccessRate(clientID string) float64 {
 }
 
 // getLastError retrieves the last error for a given client.
-func (s *Server) getLastError(clientID string) (string, time.Time) {
+func (s *Server) getLastError(tenantID, clientID string) (string, time.Time) {
 	var errorType string
 	var timestamp time.Time
 
 	err := s.db.QueryRow(`
 		SELECT error_type, timestamp
 		FROM client_history
-		WHERE client_id = ? AND success = 0
+		WHERE tenant_id = ? AND client_id = ? AND success = 0
 		ORDER BY timestamp DESC LIMIT 1`,
-		clientID).Scan(&errorType, &timestamp)
+		tenantID, clientID).Scan(&errorType, &timestamp)
 
 	if err != nil {
 		return "", time.Time{}
@@ -238,14 +640,14 @@ func (s *Server) getLastError(clientID string) (string, time.Time) {
 }
 
 // getClientHistory retrieves history data for a client over a specified duration.
-func (s *Server) getClientHistory(clientID string, duration time.Duration) ([]MonitoringData, error) {
+func (s *Server) getClientHistory(tenantID, clientID string, duration time.Duration) ([]MonitoringData, error) {
 	var history []MonitoringData
 	rows, err := s.db.Query(`
 		SELECT data
 		FROM client_history
-		WHERE client_id = ? AND timestamp > ?
+		WHERE tenant_id = ? AND client_id = ? AND timestamp > ?
 		ORDER BY timestamp ASC`,
-		clientID, time.Now().Add(-duration))
+		tenantID, clientID, time.Now().Add(-duration))
 	if err != nil {
 		return nil, err
 	}
@@ -267,16 +669,96 @@ func (s *Server) getClientHistory(clientID string, duration time.Duration) ([]Mo
 	return history, nil
 }
 
-// getAnomalies retrieves history entries where latency exceeds a threshold or an error occurred.
-func (s *Server) getAnomalies(clientID string, thresholdMs float64, duration time.Duration, limit int) ([]MonitoringData, error) {
+// deleteClient removes a tenant's client, its history and revokes its probe
+// tokens. Its alert rules go too, its other alerts are resolved and it is
+// removed from the open incidents, which close when no failing client is
+// left. It reports whether the client existed.
+func (s *Server) deleteClient(tenantID, clientID string) (bool, error) {
+	s.incidentMu.Lock()
+	defer s.incidentMu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM client_history WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE probe_tokens SET revoked_at = ? WHERE tenant_id = ? AND client_id = ? AND revoked_at IS NULL`, time.Now(), tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM target_certificates WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM checks WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM path_traces WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM probe_heartbeats WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM maintenance_windows WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM slos WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM silences WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM phase_shifts WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM remote_ips WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM ip_changes WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM alerts WHERE rule_id IN (SELECT id FROM alert_rules WHERE tenant_id = ? AND client_id = ?)`,
+		tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM alert_rules WHERE tenant_id = ? AND client_id = ?`, tenantID, clientID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE alerts SET state = ?, resolved_at = ? WHERE tenant_id = ? AND client_id = ? AND state = ?`,
+		AlertStateResolved, time.Now(), tenantID, clientID, AlertStateFiring); err != nil {
+		return false, err
+	}
+	if err := removeIncidentClient(tx, tenantID, clientID); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`DELETE FROM clients WHERE tenant_id = ? AND id = ?`, tenantID, clientID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+// getAnomalies retrieves history entries where an error occurred or the latency was abnormal:
+// flagged by the client's anomaly detection when thresholdMs is 0, above thresholdMs otherwise.
+// Samples received during maintenance are not anomalies.
+func (s *Server) getAnomalies(tenantID, clientID string, thresholdMs float64, duration time.Duration, limit int) ([]MonitoringData, error) {
 	var anomalies []MonitoringData
 	query := `
 		SELECT data
 		FROM client_history
-		WHERE client_id = ? AND (success = 0 OR latency > ?) AND timestamp > ?
+		WHERE tenant_id = ? AND client_id = ? AND (success = 0 OR (? = 0 AND anomalous = 1) OR (? > 0 AND latency > ?))
+		  AND timestamp > ? AND maintenance = 0
 		ORDER BY timestamp DESC`
 
-	args := []interface{}{clientID, thresholdMs, time.Now().Add(-duration)}
+	args := []interface{}{tenantID, clientID, thresholdMs, thresholdMs, thresholdMs, time.Now().Add(-duration)}
 
 	if limit > 0 {
 		query += ` LIMIT ?`
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Gap policies, deciding how periods without results count in a tenant's
+// success rates.
+const (
+	GapPolicyIgnore = "ignore" // Only received samples count
+	GapPolicyDown   = "down"   // Each missed sample counts as a failure
+)
+
+var errUnknownClient = errors.New("client inconnu")
+
+// newGap describes the period between two samples, or returns false when they
+// are close enough for the expected interval. The gap is clipped to from.
+func newGap(start, end, from time.Time, intervalSeconds int) (Gap, bool) {
+	interval := time.Duration(intervalSeconds) * time.Second
+	if float64(end.Sub(start)) <= lateMultiple*float64(interval) {
+		return Gap{}, false
+	}
+	if start.Before(from) {
+		start = from
+	}
+	d := end.Sub(start)
+	missed := int(d.Seconds()/float64(intervalSeconds)+0.5) - 1
+	if missed < 1 {
+		missed = 1
+	}
+	return Gap{Start: start, End: end, DurationSeconds: d.Seconds(), MissedSamples: missed}, true
+}
+
+// findGaps returns the periods of the last duration during which a client sent
+// no result for more than lateMultiple expected intervals, with the number of
+// samples received. A gap still running is marked ongoing.
+func (s *Server) findGaps(tenantID, clientID string, interval int, duration time.Duration) ([]Gap, int, error) {
+	now := time.Now()
+	from := now.Add(-duration)
+
+	// The last sample before the period tells whether it starts inside a gap
+	var previous time.Time
+	err := s.db.QueryRow(`
+		SELECT timestamp FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND timestamp <= ?
+		ORDER BY timestamp DESC LIMIT 1`,
+		tenantID, clientID, from).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT timestamp FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND timestamp > ?
+		ORDER BY timestamp ASC`,
+		tenantID, clientID, from)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	gaps := []Gap{}
+	samples := 0
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			log.Printf("Erreur de scan de l'historique pour les trous du client %s: %v", clientID, err)
+			continue
+		}
+		samples++
+		if !previous.IsZero() {
+			if gap, ok := newGap(previous, ts, from, interval); ok {
+				gaps = append(gaps, gap)
+			}
+		}
+		previous = ts
+	}
+
+	if !previous.IsZero() {
+		if gap, ok := newGap(previous, now, from, interval); ok {
+			gap.Ongoing = true
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps, samples, nil
+}
+
+// clientInterval returns the expected reporting interval of a single client.
+func (s *Server) clientInterval(tenantID, clientID string) (int, error) {
+	var declared int
+	err := s.db.QueryRow(`SELECT declared_interval FROM clients WHERE tenant_id = ? AND id = ?`,
+		tenantID, clientID).Scan(&declared)
+	if err == sql.ErrNoRows {
+		return 0, errUnknownClient
+	}
+	if err != nil {
+		return 0, err
+	}
+	checkInterval := 0
+	if def, err := s.getCheckDefinition(tenantID, clientID); err == nil {
+		checkInterval = def.Interval
+	}
+	interval, _ := s.expectedInterval(tenantID, clientID, checkInterval, declared)
+	return interval, nil
+}
+
+// getGapReport returns the gaps of a client over the last duration and the
+// share of expected samples that were received.
+func (s *Server) getGapReport(tenantID, clientID string, duration time.Duration) (GapReport, error) {
+	interval, err := s.clientInterval(tenantID, clientID)
+	if err != nil {
+		return GapReport{}, err
+	}
+	gaps, samples, err := s.findGaps(tenantID, clientID, interval, duration)
+	if err != nil {
+		return GapReport{}, err
+	}
+
+	now := time.Now()
+	report := GapReport{
+		ClientID:        clientID,
+		IntervalSeconds: interval,
+		From:            now.Add(-duration),
+		To:              now,
+		Samples:         samples,
+		Gaps:            gaps,
+	}
+	for _, g := range gaps {
+		report.MissedSamples += g.MissedSamples
+	}
+	if expected := report.Samples + report.MissedSamples; expected > 0 {
+		report.Coverage = float64(report.Samples) / float64(expected) * 100.0
+	}
+	return report, nil
+}
+
+// HandleGaps returns the periods without results of a client
+// (GET ?client_id=&duration=, 24h by default).
+func (s *Server) HandleGaps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID := r.URL.Query().Get("client_id")
+	if clientID == "" {
+		http.Error(w, "client_id requis", http.StatusBadRequest)
+		return
+	}
+	duration := 24 * time.Hour
+	if d := r.URL.Query().Get("duration"); d != "" {
+		parsed, err := parseDuration(d)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "duration invalide", http.StatusBadRequest)
+			return
+		}
+		duration = parsed
+	}
+
+	report, err := s.getGapReport(s.requestTenant(r), clientID, duration)
+	if err == errUnknownClient {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur de calcul des trous du client %s: %v", clientID, err)
+		http.Error(w, "Erreur de calcul des trous", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
\ No newline at end of file
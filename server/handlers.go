@@ -25,14 +25,48 @@ func (s *Server) HandleMonitoringData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	token, err := s.authenticateProbe(r)
+	if err != nil {
+		log.Printf("Rejet des données de monitoring de %s: %v", r.RemoteAddr, err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="probes"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	var data MonitoringData
-	err := json.NewDecoder(r.Body).Decode(&data)
+	err = json.NewDecoder(r.Body).Decode(&data)
 	if err != nil {
 		log.Printf("Erreur décodage JSON: %v", err)
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
+	// Le jeton ou le certificat est lié à un client : une sonde ne peut pas en usurper une autre
+	if data.ClientID == "" {
+		data.ClientID = token.ClientID
+	}
+	if data.ClientID != token.ClientID {
+		log.Printf("Sonde %s (%s) utilisée pour publier au nom de %s, rejet", token.ClientID, token.Name, data.ClientID)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	normalizeMonitoringData(&data)
+
+	// Vérifier l'appartenance du client et le quota du tenant avant d'accepter
+	if err := s.admitClient(token.TenantID, data.ClientID); err != nil {
+		log.Printf("Données du client %s refusées pour le tenant %s: %v", data.ClientID, token.TenantID, err)
+		switch err {
+		case errClientQuotaExceeded:
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errClientOtherTenant:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Réponse immédiate pour éviter les timeouts
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -45,21 +79,64 @@ func (s *Server) HandleMonitoringData(w http.ResponseWriter, r *http.Request) {
 
 	// Traiter les données en arrière-plan
 	go func() {
-		err = s.storeMonitoringData(data)
+		// Appliquer les assertions du check défini pour ce client
+		def, err := s.getCheckDefinition(token.TenantID, data.ClientID)
+		if err != nil && err != errUnknownCheck {
+			log.Printf("Erreur de récupération du check du client %s: %v", data.ClientID, err)
+		}
+		applyAssertions(def, &data)
+		data.Maintenance = s.inMaintenance(token.TenantID, data.ClientID, data.TargetURL, def.Tags, time.Now())
+		s.scoreAnomaly(token.TenantID, def, &data)
+		data.Site = s.resolveSite(token.TenantID, data)
+
+		// Suivre l'adresse résolue de la cible, avant le stockage pour que
+		// l'échantillon compte après un éventuel changement
+		if err := s.trackRemoteIP(token.TenantID, def, data); err != nil {
+			log.Printf("Erreur de suivi de l'adresse de %s: %v", data.TargetURL, err)
+		}
+
+		err = s.storeMonitoringData(token.TenantID, data)
 		if err != nil {
 			log.Printf("Erreur de stockage des données de monitoring: %v", err)
 			return
 		}
 
+		// Regrouper les échecs consécutifs de la cible en incidents
+		if err := s.trackIncident(token.TenantID, data); err != nil {
+			log.Printf("Erreur de suivi des incidents de %s: %v", data.TargetURL, err)
+		}
+
+		// Rechercher une rupture durable dans les phases de la requête
+		if err := s.detectPhaseShifts(token.TenantID, data.ClientID); err != nil {
+			log.Printf("Erreur de détection des ruptures de phase de %s: %v", data.ClientID, err)
+		}
+
+		// Suivre la chaîne de certificats présentée par la cible
+		if err := s.storeCertificate(token.TenantID, data); err != nil {
+			log.Printf("Erreur de stockage du certificat de %s: %v", data.ClientID, err)
+		}
+
+		// Conserver le traceroute joint à l'échantillon
+		if err := s.storePathTrace(token.TenantID, data); err != nil {
+			log.Printf("Erreur de stockage du traceroute de %s: %v", data.ClientID, err)
+		}
+
+		// Pousser l'échantillon aux abonnés WebSocket
+		s.hub.publish(token.TenantID, data)
+
 		status := "✓"
 		if data.ErrorDetails.HasError {
 			status = "✗"
 			log.Printf("[%s] %s %s - Erreur: %s",
 				time.Now().Format("15:04:05"), status, data.ClientID, data.ErrorDetails.ErrorType)
-		} else {
+		} else if data.IsHTTPCheck() {
 			log.Printf("[%s] %s %s - %dms (Statut: %d)",
 				time.Now().Format("15:04:05"), status, data.ClientID,
 				int(data.TimingMetrics.TotalResponseMs), data.ResponseDetails.StatusCode)
+		} else {
+			log.Printf("[%s] %s %s - %dms (%s)",
+				time.Now().Format("15:04:05"), status, data.ClientID,
+				int(data.TimingMetrics.TotalResponseMs), data.CheckType)
 		}
 	}()
 }
@@ -79,121 +156,34 @@ func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 	default:
 	}
 
-	clients, err := s.getClientStatuses()
-	if err != nil {
-		log.Printf("Erreur récupération données clients: %v", err)
-		http.Error(w, "Erreur de récupération des données", http.StatusInternalServerError)
-		return
-	}
-
-	// Vérifier à nouveau si la connexion est toujours active
-	select {
-	case <-ctx.Done():
-		log.Printf("Connexion fermée par le client pendant récupération des données")
-		return
-	default:
-	}
-
-	sort.Slice(clients, func(i, j int) bool {
-		if clients[i].IsOnline != clients[j].IsOnline {
-			return clients[i].IsOnline
-		}
-		return clients[i].Name < clients[j].Name
-	})
+	filterOptions, selectedDurationStr := parseDashboardQuery(r)
+	filterOptions.TenantID = s.requestTenant(r)
 
-	onlineCount := 0
-	totalLatency := 0.0
-	validLatencyCount := 0
-
-	for _, client := range clients {
-		if client.IsOnline {
-			onlineCount++
+	currentUser := userFromContext(r.Context())
+	var tenants []Tenant
+	if currentUser != nil && currentUser.TenantID == "" {
+		// Mémoriser le tenant choisi pour les rafraîchissements et l'API
+		if r.URL.Query().Get("tenant") != "" {
+			http.SetCookie(w, &http.Cookie{
+				Name:     tenantCookieName,
+				Value:    filterOptions.TenantID,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
 		}
-		if client.LastLatency > 0 {
-			totalLatency += client.LastLatency
-			validLatencyCount++
-		}
-	}
-
-	avgLatency := 0.0
-	if validLatencyCount > 0 {
-		avgLatency = totalLatency / float64(validLatencyCount)
-	}
-
-	selectedClientID := r.URL.Query().Get("client")
-	selectedDurationStr := r.URL.Query().Get("duration")
-	if selectedDurationStr == "" {
-		selectedDurationStr = "1h"
-	}
-	
-	duration, errParseDuration := time.ParseDuration(selectedDurationStr)
-	if errParseDuration != nil {
-		log.Printf("Erreur de parsing de la durée '%s': %v, utilisation par défaut 1h", selectedDurationStr, errParseDuration)
-		duration = 1 * time.Hour
-	}
-
-	sortBy := r.URL.Query().Get("sort_by")
-	if sortBy == "" {
-		sortBy = "timestamp"
-	}
-	sortOrder := r.URL.Query().Get("sort_order")
-	if sortOrder == "" {
-		sortOrder = "desc"
-	}
-	limitStr := r.URL.Query().Get("limit")
-	limit := 50
-	if limitStr != "" {
-		if l, parseErr := strconv.Atoi(limitStr); parseErr == nil && l > 0 {
-			limit = l
-		}
-	}
-	statusFilter := r.URL.Query().Get("status_filter")
-	if statusFilter == "" {
-		statusFilter = "all"
-	}
-
-	minLatencyStr := r.URL.Query().Get("min_latency")
-	minLatency := 0.0
-	if minLatencyStr != "" {
-		if ml, parseErr := strconv.ParseFloat(minLatencyStr, 64); parseErr == nil && ml >= 0 {
-			minLatency = ml
-		}
-	}
-	maxLatencyStr := r.URL.Query().Get("max_latency")
-	maxLatency := 0.0
-	if maxLatencyStr != "" {
-		if ml, parseErr := strconv.ParseFloat(maxLatencyStr, 64); parseErr == nil && ml >= 0 {
-			maxLatency = ml
+		list, err := s.listTenants()
+		if err != nil {
+			log.Printf("Erreur de récupération des tenants: %v", err)
 		}
+		tenants = list
 	}
 
-	var selectedClient *ClientStatus
-	var clientHistory []MonitoringData
-	var clientAnomalies []MonitoringData
-
-	if selectedClientID != "" {
-		for i := range clients {
-			if clients[i].ID == selectedClientID {
-				selectedClient = &clients[i]
-				break
-			}
-		}
-
-		if selectedClient != nil {
-			filterOptions := HistoryFilterOptions{
-				ClientID:     selectedClientID,
-				Duration:     duration,
-				SortBy:       sortBy,
-				SortOrder:    sortOrder,
-				Limit:        limit,
-				StatusFilter: statusFilter,
-				MinLatency:   minLatency,
-				MaxLatency:   maxLatency,
-			}
-			
-			clientHistory, _ = s.getFilteredClientHistory(filterOptions)
-			clientAnomalies, _ = s.getAnomalies(selectedClientID, 1000.0, duration, 100)
-		}
+	dashboard, err := s.loadDashboardData(filterOptions)
+	if err != nil {
+		log.Printf("Erreur récupération données clients: %v", err)
+		http.Error(w, "Erreur de récupération des données", http.StatusInternalServerError)
+		return
 	}
 
 	// Vérifier une dernière fois avant de rendre le template
@@ -206,9 +196,17 @@ func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 
 	pageData := struct {
 		DashboardData
+		CurrentUser         *User
+		CurrentTenant       string
+		Tenants             []Tenant
 		SelectedClient      *ClientStatus
 		ClientHistory       []MonitoringData
 		ClientAnomalies     []MonitoringData
+		ClientGaps          []Gap
+		ClientPhaseShifts   []PhaseShift
+		ClientRemoteIPs     []RemoteIP
+		ClientIPChanges     []IPChange
+		ClientProtocols     *ProtocolReport
 		SelectedDuration    string
 		AvailableDurations  map[string]string
 		CurrentSortBy       string
@@ -219,23 +217,31 @@ func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 		CurrentMaxLatency   float64
 	}{
 		DashboardData: DashboardData{
-			OnlineCount:    onlineCount,
-			OfflineCount:   len(clients) - onlineCount,
-			TotalCount:     len(clients),
-			AverageLatency: avgLatency,
-			Clients:        clients,
+			OnlineCount:    dashboard.OnlineCount,
+			OfflineCount:   dashboard.OfflineCount,
+			TotalCount:     dashboard.TotalCount,
+			AverageLatency: dashboard.AverageLatency,
+			Clients:        dashboard.Clients,
 		},
-		SelectedClient:      selectedClient,
-		ClientHistory:       clientHistory,
-		ClientAnomalies:     clientAnomalies,
+		CurrentUser:         currentUser,
+		CurrentTenant:       filterOptions.TenantID,
+		Tenants:             tenants,
+		SelectedClient:      dashboard.SelectedClient,
+		ClientHistory:       dashboard.ClientHistory,
+		ClientAnomalies:     dashboard.ClientAnomalies,
+		ClientGaps:          dashboard.ClientGaps,
+		ClientPhaseShifts:   dashboard.ClientPhaseShifts,
+		ClientRemoteIPs:     dashboard.ClientRemoteIPs,
+		ClientIPChanges:     dashboard.ClientIPChanges,
+		ClientProtocols:     dashboard.ClientProtocols,
 		SelectedDuration:    selectedDurationStr,
-		AvailableDurations: map[string]string{"1h": "1 heure", "6h": "6 heures", "24h": "24 heures", "7d": "7 jours", "30d": "30 jours"},
-		CurrentSortBy:       sortBy,
-		CurrentSortOrder:    sortOrder,
-		CurrentLimit:        limit,
-		CurrentStatusFilter: statusFilter,
-		CurrentMinLatency:   minLatency,
-		CurrentMaxLatency:   maxLatency,
+		AvailableDurations:  map[string]string{"1h": "1 heure", "6h": "6 heures", "24h": "24 heures", "7d": "7 jours", "30d": "30 jours"},
+		CurrentSortBy:       filterOptions.SortBy,
+		CurrentSortOrder:    filterOptions.SortOrder,
+		CurrentLimit:        filterOptions.Limit,
+		CurrentStatusFilter: filterOptions.StatusFilter,
+		CurrentMinLatency:   filterOptions.MinLatency,
+		CurrentMaxLatency:   filterOptions.MaxLatency,
 	}
 
 	tmpl := template.Must(
@@ -288,13 +294,16 @@ func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 					}
 					return t
 				},
+				"deref": func(b *bool) bool {
+					return b != nil && *b
+				},
 			}).
 			ParseFiles("templates/dashboard.html"))
 
 	// Set headers appropriés
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	
+
 	if err := tmpl.Execute(w, pageData); err != nil {
 		// Vérifier si l'erreur est due à une connexion fermée
 		if strings.Contains(err.Error(), "wsasend") || strings.Contains(err.Error(), "broken pipe") {
@@ -304,4 +313,232 @@ func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+}
+
+// HandleAPIDashboardData returns the dashboard data as JSON for the periodic refresh.
+func (s *Server) HandleAPIDashboardData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filterOptions, _ := parseDashboardQuery(r)
+	filterOptions.TenantID = s.requestTenant(r)
+
+	dashboard, err := s.loadDashboardData(filterOptions)
+	if err != nil {
+		log.Printf("Erreur récupération données clients (API): %v", err)
+		http.Error(w, "Erreur de récupération des données", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dashboard)
+}
+
+// HandleGetClients returns the current status of all clients as JSON.
+func (s *Server) HandleGetClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clients, err := s.getClientStatuses(s.requestTenant(r))
+	if err != nil {
+		log.Printf("Erreur récupération données clients (API): %v", err)
+		http.Error(w, "Erreur de récupération des données", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, clients)
+}
+
+// HandleDeleteClient deletes a client and its history (DELETE ?id=).
+func (s *Server) HandleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID := r.URL.Query().Get("id")
+	if clientID == "" {
+		http.Error(w, "id requis", http.StatusBadRequest)
+		return
+	}
+
+	tenantID := s.requestTenant(r)
+	found, err := s.deleteClient(tenantID, clientID)
+	if err != nil {
+		log.Printf("Erreur de suppression du client %s: %v", clientID, err)
+		http.Error(w, "Erreur de suppression du client", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Client introuvable", http.StatusNotFound)
+		return
+	}
+
+	s.audit(r, tenantID, userFromContext(r.Context()).Username, "client_delete", clientID, "")
+	log.Printf("Client %s supprimé", clientID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadDashboardData gathers the client statuses, global counters and, when a
+// client is selected, its filtered history and anomalies, for the tenant of
+// the filter options.
+func (s *Server) loadDashboardData(filterOptions HistoryFilterOptions) (APIDashboardData, error) {
+	clients, err := s.getClientStatuses(filterOptions.TenantID)
+	if err != nil {
+		return APIDashboardData{}, err
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].IsOnline != clients[j].IsOnline {
+			return clients[i].IsOnline
+		}
+		return clients[i].Name < clients[j].Name
+	})
+
+	onlineCount := 0
+	totalLatency := 0.0
+	validLatencyCount := 0
+
+	for _, client := range clients {
+		if client.IsOnline {
+			onlineCount++
+		}
+		if client.LastLatency > 0 {
+			totalLatency += client.LastLatency
+			validLatencyCount++
+		}
+	}
+
+	avgLatency := 0.0
+	if validLatencyCount > 0 {
+		avgLatency = totalLatency / float64(validLatencyCount)
+	}
+
+	dashboard := APIDashboardData{
+		OnlineCount:    onlineCount,
+		OfflineCount:   len(clients) - onlineCount,
+		TotalCount:     len(clients),
+		AverageLatency: avgLatency,
+		Clients:        clients,
+	}
+
+	if filterOptions.ClientID != "" {
+		for i := range clients {
+			if clients[i].ID == filterOptions.ClientID {
+				dashboard.SelectedClient = &clients[i]
+				break
+			}
+		}
+
+		if dashboard.SelectedClient != nil {
+			dashboard.ClientHistory, _ = s.getFilteredClientHistory(filterOptions)
+			dashboard.ClientAnomalies, _ = s.getAnomalies(filterOptions.TenantID, filterOptions.ClientID, 0, filterOptions.Duration, 100)
+			dashboard.ClientGaps, _, _ = s.findGaps(filterOptions.TenantID, filterOptions.ClientID, dashboard.SelectedClient.Interval, filterOptions.Duration)
+			dashboard.ClientPhaseShifts, _ = s.listPhaseShifts(filterOptions.TenantID, filterOptions.ClientID, time.Now().Add(-filterOptions.Duration))
+			dashboard.ClientRemoteIPs, _ = s.listRemoteIPs(filterOptions.TenantID, filterOptions.ClientID)
+			dashboard.ClientIPChanges, _ = s.listIPChanges(filterOptions.TenantID, filterOptions.ClientID, time.Now().Add(-filterOptions.Duration))
+			s.compareIPChanges(filterOptions.TenantID, dashboard.ClientIPChanges)
+			if protocols, err := s.getProtocolReport(filterOptions.TenantID, filterOptions.ClientID, time.Now().Add(-filterOptions.Duration)); err == nil {
+				dashboard.ClientProtocols = &protocols
+			}
+		}
+	}
+
+	return dashboard, nil
+}
+
+// maxHistoryLimit caps the number of history rows a single request can ask for.
+const maxHistoryLimit = 1000
+
+// parseDashboardQuery reads the client selection and history filters from the
+// URL query, applying the dashboard defaults. It also returns the raw duration
+// string so it can be echoed back to the page.
+func parseDashboardQuery(r *http.Request) (HistoryFilterOptions, string) {
+	query := r.URL.Query()
+
+	selectedDurationStr := query.Get("duration")
+	if selectedDurationStr == "" {
+		selectedDurationStr = "1h"
+	}
+
+	duration, errParseDuration := parseDuration(selectedDurationStr)
+	if errParseDuration != nil {
+		log.Printf("Erreur de parsing de la durée '%s': %v, utilisation par défaut 1h", selectedDurationStr, errParseDuration)
+		duration = 1 * time.Hour
+	}
+
+	sortBy := query.Get("sort_by")
+	if sortBy == "" {
+		sortBy = "timestamp"
+	}
+	sortOrder := query.Get("sort_order")
+	if sortOrder == "" {
+		sortOrder = "desc"
+	}
+	limitStr := query.Get("limit")
+	limit := 50
+	if limitStr != "" {
+		if l, parseErr := strconv.Atoi(limitStr); parseErr == nil && l > 0 {
+			limit = l
+		}
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	statusFilter := query.Get("status_filter")
+	if statusFilter == "" {
+		statusFilter = "all"
+	}
+
+	minLatencyStr := query.Get("min_latency")
+	minLatency := 0.0
+	if minLatencyStr != "" {
+		if ml, parseErr := strconv.ParseFloat(minLatencyStr, 64); parseErr == nil && ml >= 0 {
+			minLatency = ml
+		}
+	}
+	maxLatencyStr := query.Get("max_latency")
+	maxLatency := 0.0
+	if maxLatencyStr != "" {
+		if ml, parseErr := strconv.ParseFloat(maxLatencyStr, 64); parseErr == nil && ml >= 0 {
+			maxLatency = ml
+		}
+	}
+
+	return HistoryFilterOptions{
+		ClientID:     query.Get("client"),
+		Duration:     duration,
+		SortBy:       sortBy,
+		SortOrder:    sortOrder,
+		Limit:        limit,
+		StatusFilter: statusFilter,
+		MinLatency:   minLatency,
+		MaxLatency:   maxLatency,
+	}, selectedDurationStr
+}
+
+// parseDuration extends time.ParseDuration with a day unit ("7d", "30d"),
+// which the dashboard offers but the standard library does not support.
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("durée invalide: %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Erreur d'encodage de la réponse JSON: %v", err)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultHeartbeatInterval is assumed for probes that do not declare theirs.
+const defaultHeartbeatInterval = 30
+
+// probeHeartbeatMisses is the number of heartbeats a probe may miss before it
+// is considered down.
+const probeHeartbeatMisses = 3
+
+// Uptime returns the probe uptime reported in the heartbeat.
+func (hb ProbeHeartbeat) Uptime() time.Duration {
+	return time.Duration(hb.UptimeSeconds) * time.Second
+}
+
+// isProbeOnline reports whether a probe sent a heartbeat recently enough.
+func isProbeOnline(hb *ProbeHeartbeat, now time.Time) bool {
+	if hb == nil {
+		return false
+	}
+	interval := hb.IntervalSeconds
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	return now.Sub(hb.ReceivedAt) < time.Duration(probeHeartbeatMisses*interval)*time.Second
+}
+
+// storeHeartbeat records the last heartbeat of a client's probe. A probe that
+// has not sent any result yet is registered as a client, so that it shows up
+// on the dashboard; its last result time stays unset until then.
+func (s *Server) storeHeartbeat(tenantID string, hb ProbeHeartbeat) error {
+	if _, err := s.db.Exec(`
+		INSERT INTO clients (id, name, target_url, tenant_id)
+		VALUES (?, ?, '', ?)
+		ON CONFLICT(id) DO NOTHING`,
+		hb.ClientID, hb.ClientID, tenantID); err != nil {
+		return err
+	}
+
+	data, _ := json.Marshal(hb)
+	_, err := s.db.Exec(`
+		INSERT INTO probe_heartbeats (tenant_id, client_id, received_at, data)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(tenant_id, client_id) DO UPDATE SET
+			received_at = excluded.received_at,
+			data = excluded.data`,
+		tenantID, hb.ClientID, hb.ReceivedAt, string(data))
+	return err
+}
+
+// HandleHeartbeat receives the periodic self-report of a probe. It is
+// authenticated like /data and independent of check results, so that a probe
+// whose target is down is not mistaken for a dead probe.
+func (s *Server) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, err := s.authenticateProbe(r)
+	if err != nil {
+		log.Printf("Rejet du heartbeat de %s: %v", r.RemoteAddr, err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="probes"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var hb ProbeHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if hb.ClientID == "" {
+		hb.ClientID = token.ClientID
+	}
+	if hb.ClientID != token.ClientID {
+		log.Printf("Sonde %s (%s) utilisée pour un heartbeat au nom de %s, rejet", token.ClientID, token.Name, hb.ClientID)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if err := s.admitClient(token.TenantID, hb.ClientID); err != nil {
+		log.Printf("Heartbeat du client %s refusé pour le tenant %s: %v", hb.ClientID, token.TenantID, err)
+		switch err {
+		case errClientQuotaExceeded:
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errClientOtherTenant:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	hb.ReceivedAt = time.Now()
+	hb.RemoteAddr = r.RemoteAddr
+	if err := s.storeHeartbeat(token.TenantID, hb); err != nil {
+		log.Printf("Erreur de stockage du heartbeat de %s: %v", hb.ClientID, err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
\ No newline at end of file
@@ -0,0 +1,628 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Incident states.
+const (
+	IncidentStateOpen   = "open"
+	IncidentStateClosed = "closed"
+)
+
+// errorTypeHighLatency is the error type of bad samples that were only
+// abnormally slow.
+const errorTypeHighLatency = "high_latency"
+
+// incidentExpiryMultiple is the number of expected intervals a failing client
+// may go without reporting the target of an incident before it stops keeping
+// the incident open.
+const incidentExpiryMultiple = staleMultiple
+
+var errUnknownIncident = errors.New("incident inconnu")
+
+// sampleErrorType returns the error type a bad sample counts under.
+func sampleErrorType(data MonitoringData) string {
+	if !data.ErrorDetails.HasError {
+		return errorTypeHighLatency
+	}
+	if data.ErrorDetails.ErrorType == "" {
+		return "error"
+	}
+	return data.ErrorDetails.ErrorType
+}
+
+// isBadSample reports whether a sample failed or was flagged as abnormally
+// slow by the anomaly detection of its client.
+func isBadSample(data MonitoringData) bool {
+	return data.ErrorDetails.HasError || data.Anomalous
+}
+
+// trackIncident updates the incident of the sample's target. A bad sample
+// opens an incident or extends the open one, and classifies it from the
+// other clients and targets; a good sample marks its client as recovered, and
+// the incident closes once every affected client has recovered or stopped
+// reporting the target. Samples received during maintenance are ignored.
+func (s *Server) trackIncident(tenantID string, data MonitoringData) error {
+	if data.Maintenance {
+		return nil
+	}
+	s.incidentMu.Lock()
+	defer s.incidentMu.Unlock()
+
+	now := time.Now()
+	bad := isBadSample(data)
+	latency := data.TimingMetrics.TotalResponseMs
+
+	var id int64
+	var samples int
+	var peak float64
+	var lastBadAt time.Time
+	var countsJSON, clientsJSON sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, samples, peak_latency, last_bad_at, error_counts, clients FROM incidents
+		WHERE tenant_id = ? AND target_url = ? AND state = ?
+		ORDER BY started_at DESC LIMIT 1`,
+		tenantID, data.TargetURL, IncidentStateOpen).Scan(&id, &samples, &peak, &lastBadAt, &countsJSON, &clientsJSON)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	counts := make(map[string]int)
+	failing := make(map[string]bool) // Client ID to whether its last sample was bad
+	if err == nil {
+		json.Unmarshal([]byte(countsJSON.String), &counts)
+		json.Unmarshal([]byte(clientsJSON.String), &failing)
+		// An incident left open by clients that went silent ends at its last
+		// bad sample, and a new bad sample starts another one
+		if !s.pruneIncidentClients(tenantID, data.TargetURL, data.ClientID, failing, now) {
+			if err := s.closeIncident(id, data.TargetURL, lastBadAt, failing); err != nil {
+				return err
+			}
+			err = sql.ErrNoRows
+		}
+	}
+
+	if err == sql.ErrNoRows {
+		if !bad {
+			return nil
+		}
+		counts, _ := json.Marshal(map[string]int{sampleErrorType(data): 1})
+		clients, _ := json.Marshal(map[string]bool{data.ClientID: true})
+		scope, err := s.classifyIncident(tenantID, data.TargetURL, []string{data.ClientID})
+		if err != nil {
+			log.Printf("Erreur de corrélation de l'incident sur %s: %v", data.TargetURL, err)
+		}
+		res, err := s.db.Exec(`
+			INSERT INTO incidents (tenant_id, target_url, state, started_at, last_bad_at, samples, peak_latency, error_counts, clients, classification)
+			VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?, ?)`,
+			tenantID, data.TargetURL, IncidentStateOpen, now, now, latency, string(counts), string(clients), scope)
+		if err != nil {
+			return err
+		}
+		id, _ = res.LastInsertId()
+		log.Printf("🚨 Incident %d ouvert sur %s (%s, client %s, %s)", id, data.TargetURL, sampleErrorType(data), data.ClientID, scope)
+		return nil
+	}
+
+	if bad {
+		counts[sampleErrorType(data)]++
+		failing[data.ClientID] = true
+		if latency > peak {
+			peak = latency
+		}
+		var stillFailing []string
+		for clientID, f := range failing {
+			if f {
+				stillFailing = append(stillFailing, clientID)
+			}
+		}
+		scope, err := s.classifyIncident(tenantID, data.TargetURL, stillFailing)
+		if err != nil {
+			log.Printf("Erreur de corrélation de l'incident %d: %v", id, err)
+		}
+		countsOut, _ := json.Marshal(counts)
+		clientsOut, _ := json.Marshal(failing)
+		_, err = s.db.Exec(`
+			UPDATE incidents SET last_bad_at = ?, samples = ?, peak_latency = ?, error_counts = ?, clients = ?, classification = ?
+			WHERE id = ?`,
+			now, samples+1, peak, string(countsOut), string(clientsOut), scope, id)
+		return err
+	}
+
+	if _, affected := failing[data.ClientID]; affected {
+		failing[data.ClientID] = false
+	}
+	clientsOut, _ := json.Marshal(failing)
+	if string(clientsOut) == clientsJSON.String {
+		return nil
+	}
+	for _, f := range failing {
+		if f {
+			_, err := s.db.Exec(`UPDATE incidents SET clients = ? WHERE id = ?`, string(clientsOut), id)
+			return err
+		}
+	}
+	return s.closeIncident(id, data.TargetURL, now, failing)
+}
+
+// pruneIncidentClients marks as recovered the failing clients of an incident
+// that have not reported its target for incidentExpiryMultiple of their
+// expected intervals: stopped, deleted or pointed at another target. The
+// client whose sample is being processed is known to report it. It returns
+// whether a failing client is left.
+func (s *Server) pruneIncidentClients(tenantID, targetURL, reportingID string, failing map[string]bool, now time.Time) bool {
+	left := false
+	for clientID, f := range failing {
+		if !f || clientID == reportingID {
+			left = left || f
+			continue
+		}
+		interval, err := s.clientInterval(tenantID, clientID)
+		if err == errUnknownClient {
+			failing[clientID] = false
+			continue
+		}
+		if err != nil {
+			log.Printf("Erreur de récupération de l'intervalle du client %s: %v", clientID, err)
+			left = true
+			continue
+		}
+		var reporting bool
+		since := now.Add(-time.Duration(incidentExpiryMultiple*float64(interval)) * time.Second)
+		if err := s.db.QueryRow(`
+			SELECT COUNT(*) > 0 FROM client_history
+			WHERE tenant_id = ? AND client_id = ? AND target_url = ? AND timestamp > ?`,
+			tenantID, clientID, targetURL, since).Scan(&reporting); err != nil {
+			log.Printf("Erreur de vérification de l'historique du client %s: %v", clientID, err)
+			reporting = true
+		}
+		if reporting {
+			left = true
+		} else {
+			failing[clientID] = false
+		}
+	}
+	return left
+}
+
+// closeIncident closes an incident at a given time.
+func (s *Server) closeIncident(id int64, targetURL string, at time.Time, failing map[string]bool) error {
+	clientsOut, _ := json.Marshal(failing)
+	_, err := s.db.Exec(`UPDATE incidents SET state = ?, ended_at = ?, clients = ? WHERE id = ?`,
+		IncidentStateClosed, at, string(clientsOut), id)
+	if err == nil {
+		log.Printf("✅ Incident %d clos sur %s", id, targetURL)
+	}
+	return err
+}
+
+// removeIncidentClient removes a deleted client from the open incidents of its
+// tenant, closing at their last bad sample those it was the last failing
+// client of.
+func removeIncidentClient(tx *sql.Tx, tenantID, clientID string) error {
+	rows, err := tx.Query(`
+		SELECT id, last_bad_at, clients FROM incidents
+		WHERE tenant_id = ? AND state = ? AND EXISTS (SELECT 1 FROM json_each(clients) WHERE key = ?)`,
+		tenantID, IncidentStateOpen, clientID)
+	if err != nil {
+		return err
+	}
+	type openIncident struct {
+		id          int64
+		lastBadAt   time.Time
+		clientsJSON string
+	}
+	var open []openIncident
+	for rows.Next() {
+		var inc openIncident
+		if err := rows.Scan(&inc.id, &inc.lastBadAt, &inc.clientsJSON); err != nil {
+			log.Printf("Erreur de scan des incidents du client %s: %v", clientID, err)
+			continue
+		}
+		open = append(open, inc)
+	}
+	rows.Close()
+
+	for _, inc := range open {
+		failing := make(map[string]bool)
+		json.Unmarshal([]byte(inc.clientsJSON), &failing)
+		delete(failing, clientID)
+		clientsOut, _ := json.Marshal(failing)
+		stillFailing := false
+		for _, f := range failing {
+			stillFailing = stillFailing || f
+		}
+		if stillFailing {
+			_, err = tx.Exec(`UPDATE incidents SET clients = ? WHERE id = ?`, string(clientsOut), inc.id)
+		} else {
+			_, err = tx.Exec(`UPDATE incidents SET state = ?, ended_at = ?, clients = ? WHERE id = ?`,
+				IncidentStateClosed, inc.lastBadAt, string(clientsOut), inc.id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireIncidents closes, at their last bad sample, the open incidents whose
+// failing clients have all stopped reporting their target.
+func (s *Server) expireIncidents() {
+	s.incidentMu.Lock()
+	defer s.incidentMu.Unlock()
+
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, target_url, last_bad_at, COALESCE(clients, '')
+		FROM incidents WHERE state = ?`, IncidentStateOpen)
+	if err != nil {
+		log.Printf("Erreur de récupération des incidents ouverts: %v", err)
+		return
+	}
+	type openIncident struct {
+		id                  int64
+		tenantID, targetURL string
+		lastBadAt           time.Time
+		clientsJSON         string
+	}
+	var open []openIncident
+	for rows.Next() {
+		var inc openIncident
+		if err := rows.Scan(&inc.id, &inc.tenantID, &inc.targetURL, &inc.lastBadAt, &inc.clientsJSON); err != nil {
+			log.Printf("Erreur de scan des incidents ouverts: %v", err)
+			continue
+		}
+		open = append(open, inc)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, inc := range open {
+		failing := make(map[string]bool)
+		json.Unmarshal([]byte(inc.clientsJSON), &failing)
+		if s.pruneIncidentClients(inc.tenantID, inc.targetURL, "", failing, now) {
+			continue
+		}
+		if err := s.closeIncident(inc.id, inc.targetURL, inc.lastBadAt, failing); err != nil {
+			log.Printf("Erreur de clôture de l'incident %d: %v", inc.id, err)
+		}
+	}
+}
+
+// queryIncidents returns the incidents matching a WHERE clause, without notes.
+func (s *Server) queryIncidents(where string, args ...interface{}) ([]Incident, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, target_url, state, started_at, last_bad_at, ended_at, samples, peak_latency,
+		       COALESCE(error_counts, ''), COALESCE(clients, ''), classification, COALESCE(root_cause, '')
+		FROM incidents `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	incidents := []Incident{}
+	for rows.Next() {
+		var inc Incident
+		var endedAt sql.NullTime
+		var countsJSON, clientsJSON string
+		if err := rows.Scan(&inc.ID, &inc.TenantID, &inc.TargetURL, &inc.State, &inc.StartedAt, &inc.LastBadAt, &endedAt,
+			&inc.Samples, &inc.PeakLatencyMs, &countsJSON, &clientsJSON, &inc.Classification, &inc.RootCause); err != nil {
+			log.Printf("Erreur de scan des incidents: %v", err)
+			continue
+		}
+
+		end := now
+		if endedAt.Valid {
+			inc.EndedAt = &endedAt.Time
+			end = endedAt.Time
+		}
+		inc.DurationSeconds = end.Sub(inc.StartedAt).Seconds()
+
+		inc.ErrorCounts = make(map[string]int)
+		json.Unmarshal([]byte(countsJSON), &inc.ErrorCounts)
+		for errorType, n := range inc.ErrorCounts {
+			if n > inc.ErrorCounts[inc.DominantError] || (n == inc.ErrorCounts[inc.DominantError] && errorType < inc.DominantError) {
+				inc.DominantError = errorType
+			}
+		}
+
+		var clients map[string]bool
+		json.Unmarshal([]byte(clientsJSON), &clients)
+		inc.AffectedClients = []string{}
+		for clientID := range clients {
+			inc.AffectedClients = append(inc.AffectedClients, clientID)
+		}
+		sort.Strings(inc.AffectedClients)
+
+		incidents = append(incidents, inc)
+	}
+	return incidents, nil
+}
+
+// listIncidents returns the incidents of a tenant started or still open since
+// a given time, most recent first, in the given state ("" for all) and
+// affecting the given client ("" for all).
+func (s *Server) listIncidents(tenantID, state, clientID string, since time.Time, limit int) ([]Incident, error) {
+	return s.queryIncidents(`
+		WHERE tenant_id = ? AND (? = '' OR state = ?)
+		  AND (? = '' OR EXISTS (SELECT 1 FROM json_each(clients) WHERE key = ?))
+		  AND (started_at > ? OR ended_at IS NULL OR ended_at > ?)
+		ORDER BY started_at DESC
+		LIMIT ?`,
+		tenantID, state, state, clientID, clientID, since, since, limit)
+}
+
+// getIncident returns an incident of a tenant with its notes.
+func (s *Server) getIncident(tenantID string, id int64) (Incident, error) {
+	incidents, err := s.queryIncidents(`WHERE tenant_id = ? AND id = ?`, tenantID, id)
+	if err != nil {
+		return Incident{}, err
+	}
+	if len(incidents) == 0 {
+		return Incident{}, errUnknownIncident
+	}
+	err = s.loadIncidentNotes(incidents)
+	return incidents[0], err
+}
+
+// loadIncidentNotes fills the notes of incidents in a single query.
+func (s *Server) loadIncidentNotes(incidents []Incident) error {
+	if len(incidents) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Incident, len(incidents))
+	args := make([]interface{}, len(incidents))
+	for i := range incidents {
+		byID[incidents[i].ID] = &incidents[i]
+		args[i] = incidents[i].ID
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, incident_id, COALESCE(author, ''), note, created_at
+		FROM incident_notes WHERE incident_id IN (?`+strings.Repeat(", ?", len(args)-1)+`)
+		ORDER BY created_at`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var note IncidentNote
+		if err := rows.Scan(&note.ID, &note.IncidentID, &note.Author, &note.Note, &note.CreatedAt); err != nil {
+			log.Printf("Erreur de scan des notes d'incident: %v", err)
+			continue
+		}
+		inc := byID[note.IncidentID]
+		inc.Notes = append(inc.Notes, note)
+	}
+	return nil
+}
+
+// HandleIncidents lists the incidents of the tenant (GET
+// ?state=open|closed|all&client_id=&days=&limit=, or ?id= for one with its
+// notes), sets the root cause of one (PUT ?id=) or annotates it (POST ?id=).
+// Changes require the operator role.
+func (s *Server) HandleIncidents(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	tenantID := s.requestTenant(r)
+	if r.Method != http.MethodGet && !user.hasRole(RoleOperator) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	var id int64
+	if v := query.Get("id"); v != "" || r.Method != http.MethodGet {
+		var err error
+		if id, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if id != 0 {
+			inc, err := s.getIncident(tenantID, id)
+			if err == errUnknownIncident {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Erreur de récupération de l'incident %d: %v", id, err)
+				http.Error(w, "Erreur de récupération des incidents", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, inc)
+			return
+		}
+
+		state := query.Get("state")
+		switch state {
+		case "", "all":
+			state = ""
+		case IncidentStateOpen, IncidentStateClosed:
+		default:
+			http.Error(w, "state invalide", http.StatusBadRequest)
+			return
+		}
+		days := 7
+		if d, err := strconv.Atoi(query.Get("days")); err == nil && d > 0 {
+			days = d
+		}
+		limit := 200
+		if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+			limit = l
+		}
+		incidents, err := s.listIncidents(tenantID, state, query.Get("client_id"), time.Now().AddDate(0, 0, -days), limit)
+		if err != nil {
+			log.Printf("Erreur de récupération des incidents: %v", err)
+			http.Error(w, "Erreur de récupération des incidents", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, incidents)
+
+	case http.MethodPut:
+		var req struct {
+			RootCause string `json:"root_cause"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		res, err := s.db.Exec(`UPDATE incidents SET root_cause = ? WHERE id = ? AND tenant_id = ?`,
+			strings.TrimSpace(req.RootCause), id, tenantID)
+		if err != nil {
+			log.Printf("Erreur de mise à jour de l'incident %d: %v", id, err)
+			http.Error(w, "Erreur de mise à jour de l'incident", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, errUnknownIncident.Error(), http.StatusNotFound)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "incident_root_cause", strconv.FormatInt(id, 10), req.RootCause)
+		inc, _ := s.getIncident(tenantID, id)
+		writeJSON(w, http.StatusOK, inc)
+
+	case http.MethodPost:
+		var note IncidentNote
+		if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		note.Note = strings.TrimSpace(note.Note)
+		if note.Note == "" {
+			http.Error(w, "note requise", http.StatusBadRequest)
+			return
+		}
+		if _, err := s.getIncident(tenantID, id); err != nil {
+			http.Error(w, errUnknownIncident.Error(), http.StatusNotFound)
+			return
+		}
+		note.IncidentID = id
+		note.Author = user.Username
+		note.CreatedAt = time.Now()
+		res, err := s.db.Exec(`INSERT INTO incident_notes (incident_id, author, note, created_at) VALUES (?, ?, ?, ?)`,
+			note.IncidentID, note.Author, note.Note, note.CreatedAt)
+		if err != nil {
+			log.Printf("Erreur d'ajout de note à l'incident %d: %v", id, err)
+			http.Error(w, "Erreur d'ajout de la note", http.StatusInternalServerError)
+			return
+		}
+		note.ID, _ = res.LastInsertId()
+		s.audit(r, tenantID, user.Username, "incident_note", strconv.FormatInt(id, 10), "")
+		writeJSON(w, http.StatusCreated, note)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// timelineSegment places an incident on the timeline, in percent of its width.
+type timelineSegment struct {
+	Incident Incident
+	Left     float64
+	Width    float64
+}
+
+// HandleIncidentsPage renders the incident timeline of the tenant (GET ?days=).
+func (s *Server) HandleIncidentsPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := 7
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 && d <= 90 {
+		days = d
+	}
+	tenantID := s.requestTenant(r)
+	now := time.Now()
+	from := now.AddDate(0, 0, -days)
+	incidents, err := s.listIncidents(tenantID, "", "", from, 500)
+	if err != nil {
+		log.Printf("Erreur de récupération des incidents: %v", err)
+		http.Error(w, "Erreur de récupération des incidents", http.StatusInternalServerError)
+		return
+	}
+	if err := s.loadIncidentNotes(incidents); err != nil {
+		log.Printf("Erreur de récupération des notes d'incident: %v", err)
+	}
+
+	// One timeline row per target, targets in order of their last incident
+	span := now.Sub(from).Seconds()
+	var targets []string
+	rows := make(map[string][]timelineSegment)
+	for _, inc := range incidents {
+		start := inc.StartedAt
+		if start.Before(from) {
+			start = from
+		}
+		left := start.Sub(from).Seconds() / span * 100
+		width := (inc.DurationSeconds - start.Sub(inc.StartedAt).Seconds()) / span * 100
+		if width < 0.4 {
+			width = 0.4
+		}
+		if _, ok := rows[inc.TargetURL]; !ok {
+			targets = append(targets, inc.TargetURL)
+		}
+		rows[inc.TargetURL] = append(rows[inc.TargetURL], timelineSegment{Incident: inc, Left: left, Width: width})
+	}
+	type timelineRow struct {
+		Target   string
+		Segments []timelineSegment
+	}
+	var timeline []timelineRow
+	for _, target := range targets {
+		timeline = append(timeline, timelineRow{Target: target, Segments: rows[target]})
+	}
+
+	tmpl, err := template.New("incidents.html").
+		Funcs(template.FuncMap{
+			"formatTime": func(t time.Time) string {
+				return t.Format("02/01/2006 15:04:05")
+			},
+			"formatSeconds": formatSeconds,
+		}).
+		ParseFiles("templates/incidents.html")
+	if err != nil {
+		log.Printf("Erreur de chargement du template des incidents: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	user := userFromContext(r.Context())
+	pageData := struct {
+		CurrentUser   *User
+		CurrentTenant string
+		CanAnnotate   bool
+		Days          int
+		From          time.Time
+		Incidents     []Incident
+		Timeline      []timelineRow
+	}{
+		CurrentUser:   user,
+		CurrentTenant: tenantID,
+		CanAnnotate:   user.hasRole(RoleOperator),
+		Days:          days,
+		From:          from,
+		Incidents:     incidents,
+		Timeline:      timeline,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := tmpl.Execute(w, pageData); err != nil {
+		log.Printf("Erreur lors de l'exécution du template des incidents: %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// validateMaintenanceWindow checks a one-off window (start and end) or a
+// recurring one (cron and duration, optionally bounded by start and end).
+func validateMaintenanceWindow(mw *MaintenanceWindow) error {
+	if mw.Cron == "" {
+		if mw.Start.IsZero() || !mw.End.After(mw.Start) {
+			return errors.New("start et end requis, end après start")
+		}
+		mw.DurationMinutes = 0
+		return nil
+	}
+	if _, err := parseCron(mw.Cron); err != nil {
+		return err
+	}
+	if mw.DurationMinutes <= 0 || mw.DurationMinutes > 7*24*60 {
+		return errors.New("duration_minutes requis pour une fenêtre récurrente (7 jours au plus)")
+	}
+	if !mw.Start.IsZero() && !mw.End.IsZero() && !mw.End.After(mw.Start) {
+		return errors.New("end doit être après start")
+	}
+	return nil
+}
+
+// appliesTo reports whether the window covers a client, given its target and
+// tags. A window without restriction covers the whole tenant.
+func (mw MaintenanceWindow) appliesTo(clientID, targetURL string, tags []string) bool {
+	if mw.ClientID != "" && mw.ClientID != clientID {
+		return false
+	}
+	if mw.TargetURL != "" && mw.TargetURL != targetURL {
+		return false
+	}
+	if mw.Tag != "" {
+		for _, tag := range tags {
+			if tag == mw.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// maxListedOccurrences caps the occurrences returned when listing the
+// maintenance periods of a range.
+const maxListedOccurrences = 1000
+
+// occurrences returns the periods of the window overlapping [from, to): the
+// window itself when it is one-off, one copy per start time otherwise, the
+// first max of them when max is positive.
+func (mw MaintenanceWindow) occurrences(from, to time.Time, max int) []MaintenanceWindow {
+	if mw.Cron == "" {
+		if mw.Start.Before(to) && mw.End.After(from) {
+			return []MaintenanceWindow{mw}
+		}
+		return nil
+	}
+
+	schedule, err := parseCron(mw.Cron)
+	if err != nil {
+		log.Printf("Fenêtre de maintenance %d: %v", mw.ID, err)
+		return nil
+	}
+	duration := time.Duration(mw.DurationMinutes) * time.Minute
+	start := from.Add(-duration)
+	if !mw.Start.IsZero() && mw.Start.After(start) {
+		start = mw.Start
+	}
+	limit := to
+	if !mw.End.IsZero() && mw.End.Before(limit) {
+		limit = mw.End
+	}
+
+	var found []MaintenanceWindow
+	for t, ok := schedule.next(start.In(time.Local), limit); ok; t, ok = schedule.next(t.Add(time.Minute), limit) {
+		if !t.Add(duration).After(from) {
+			continue
+		}
+		occurrence := mw
+		occurrence.Start = t
+		occurrence.End = t.Add(duration)
+		found = append(found, occurrence)
+		if max > 0 && len(found) >= max {
+			break
+		}
+	}
+	return found
+}
+
+// activeAt returns the period of the window covering a given time, if any.
+// For a recurring window, only its last start within its duration before
+// that time is looked up.
+func (mw MaintenanceWindow) activeAt(at time.Time) (MaintenanceWindow, bool) {
+	if mw.Cron == "" {
+		return mw, !at.Before(mw.Start) && at.Before(mw.End)
+	}
+
+	schedule, err := parseCron(mw.Cron)
+	if err != nil {
+		log.Printf("Fenêtre de maintenance %d: %v", mw.ID, err)
+		return mw, false
+	}
+	duration := time.Duration(mw.DurationMinutes) * time.Minute
+	earliest := at.Add(-duration)
+	if !mw.Start.IsZero() && mw.Start.After(earliest) {
+		earliest = mw.Start
+	}
+	t, ok := schedule.prev(at.In(time.Local), earliest)
+	if !ok || !t.Add(duration).After(at) || (!mw.End.IsZero() && !t.Before(mw.End)) {
+		return mw, false
+	}
+	occurrence := mw
+	occurrence.Start = t
+	occurrence.End = t.Add(duration)
+	return occurrence, true
+}
+
+// listMaintenanceWindows returns the maintenance windows defined for a tenant.
+func (s *Server) listMaintenanceWindows(tenantID string) ([]MaintenanceWindow, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, client_id, target_url, tag, start_time, end_time, cron, duration_minutes, reason, created_by, created_at
+		FROM maintenance_windows
+		WHERE tenant_id = ?
+		ORDER BY start_time`,
+		tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var mw MaintenanceWindow
+		var reason, createdBy sql.NullString
+		var createdAt sql.NullTime
+		if err := rows.Scan(&mw.ID, &mw.TenantID, &mw.ClientID, &mw.TargetURL, &mw.Tag, &mw.Start, &mw.End,
+			&mw.Cron, &mw.DurationMinutes, &reason, &createdBy, &createdAt); err != nil {
+			log.Printf("Erreur de scan des fenêtres de maintenance: %v", err)
+			continue
+		}
+		mw.Reason = reason.String
+		mw.CreatedBy = createdBy.String
+		mw.CreatedAt = createdAt.Time
+		windows = append(windows, mw)
+	}
+	return windows, nil
+}
+
+// maintenanceOccurrences returns the maintenance periods of a tenant
+// overlapping [from, to), recurring windows expanded, sorted by start. With a
+// positive max, only the first max periods are returned.
+func (s *Server) maintenanceOccurrences(tenantID string, from, to time.Time, max int) ([]MaintenanceWindow, error) {
+	windows, err := s.listMaintenanceWindows(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	occurrences := []MaintenanceWindow{}
+	for _, mw := range windows {
+		occurrences = append(occurrences, mw.occurrences(from, to, max)...)
+	}
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].Start.Before(occurrences[j].Start) })
+	if max > 0 && len(occurrences) > max {
+		occurrences = occurrences[:max]
+	}
+	return occurrences, nil
+}
+
+// activeMaintenance returns the maintenance periods of a tenant running at a
+// given time.
+func (s *Server) activeMaintenance(tenantID string, at time.Time) ([]MaintenanceWindow, error) {
+	windows, err := s.listMaintenanceWindows(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var active []MaintenanceWindow
+	for _, mw := range windows {
+		if occurrence, ok := mw.activeAt(at); ok {
+			active = append(active, occurrence)
+		}
+	}
+	return active, nil
+}
+
+// inMaintenance reports whether a client is covered by a maintenance window
+// at the given time.
+func (s *Server) inMaintenance(tenantID, clientID, targetURL string, tags []string, at time.Time) bool {
+	occurrences, err := s.activeMaintenance(tenantID, at)
+	if err != nil {
+		log.Printf("Erreur de récupération des fenêtres de maintenance: %v", err)
+		return false
+	}
+	for _, mw := range occurrences {
+		if mw.appliesTo(clientID, targetURL, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveMaintenanceWindow creates a maintenance window.
+func (s *Server) saveMaintenanceWindow(mw *MaintenanceWindow) error {
+	if err := validateMaintenanceWindow(mw); err != nil {
+		return err
+	}
+	mw.CreatedAt = time.Now()
+	res, err := s.db.Exec(`
+		INSERT INTO maintenance_windows (tenant_id, client_id, target_url, tag, start_time, end_time, cron, duration_minutes, reason, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		mw.TenantID, mw.ClientID, mw.TargetURL, mw.Tag, mw.Start, mw.End, mw.Cron, mw.DurationMinutes, mw.Reason, mw.CreatedBy, mw.CreatedAt)
+	if err != nil {
+		return err
+	}
+	mw.ID, err = res.LastInsertId()
+	return err
+}
+
+// maintenanceOverlap returns how much of [start, end) is covered by the
+// windows, counting overlapping windows once.
+func maintenanceOverlap(start, end time.Time, windows []MaintenanceWindow) time.Duration {
+	var spans [][2]time.Time
+	for _, mw := range windows {
+		s, e := mw.Start, mw.End
+		if s.Before(start) {
+			s = start
+		}
+		if e.After(end) {
+			e = end
+		}
+		if e.After(s) {
+			spans = append(spans, [2]time.Time{s, e})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0].Before(spans[j][0]) })
+
+	var covered time.Duration
+	var cursor time.Time
+	for _, span := range spans {
+		if span[0].Before(cursor) {
+			span[0] = cursor
+		}
+		if span[1].After(span[0]) {
+			covered += span[1].Sub(span[0])
+			cursor = span[1]
+		}
+	}
+	return covered
+}
+
+// HandleMaintenance lists the maintenance windows (GET, ?from=&to= in RFC
+// 3339 for their first maxListedOccurrences occurrences over a period),
+// creates (POST) and deletes (DELETE ?id=) them. Changes require the operator
+// role.
+func (s *Server) HandleMaintenance(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	tenantID := s.requestTenant(r)
+	if r.Method != http.MethodGet && !user.hasRole(RoleOperator) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if query.Get("from") == "" && query.Get("to") == "" {
+			windows, err := s.listMaintenanceWindows(tenantID)
+			if err != nil {
+				log.Printf("Erreur de récupération des fenêtres de maintenance: %v", err)
+				http.Error(w, "Erreur de récupération des fenêtres de maintenance", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, windows)
+			return
+		}
+
+		from := time.Now()
+		to := from.AddDate(0, 1, 0)
+		if v := query.Get("from"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "from invalide", http.StatusBadRequest)
+				return
+			}
+			from = t
+		}
+		if v := query.Get("to"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "to invalide", http.StatusBadRequest)
+				return
+			}
+			to = t
+		}
+		if to.Sub(from) > 366*24*time.Hour {
+			http.Error(w, "période trop longue (un an au plus)", http.StatusBadRequest)
+			return
+		}
+		occurrences, err := s.maintenanceOccurrences(tenantID, from, to, maxListedOccurrences)
+		if err != nil {
+			log.Printf("Erreur de récupération des fenêtres de maintenance: %v", err)
+			http.Error(w, "Erreur de récupération des fenêtres de maintenance", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, occurrences)
+
+	case http.MethodPost:
+		var mw MaintenanceWindow
+		if err := json.NewDecoder(r.Body).Decode(&mw); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		mw.TenantID = tenantID
+		mw.CreatedBy = user.Username
+		if err := s.saveMaintenanceWindow(&mw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		details := mw.Start.Format(time.RFC3339) + " - " + mw.End.Format(time.RFC3339)
+		if mw.Cron != "" {
+			details = "cron=" + mw.Cron + " duration_minutes=" + strconv.Itoa(mw.DurationMinutes)
+		}
+		s.audit(r, tenantID, user.Username, "maintenance_create", strconv.FormatInt(mw.ID, 10), details)
+		writeJSON(w, http.StatusCreated, mw)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		res, err := s.db.Exec(`DELETE FROM maintenance_windows WHERE id = ? AND tenant_id = ?`, id, tenantID)
+		if err != nil {
+			log.Printf("Erreur de suppression de la fenêtre de maintenance %d: %v", id, err)
+			http.Error(w, "Erreur de suppression de la fenêtre de maintenance", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Fenêtre de maintenance introuvable", http.StatusNotFound)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "maintenance_delete", strconv.FormatInt(id, 10), "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
\ No newline at end of file
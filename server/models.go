@@ -4,12 +4,12 @@ import "time"
 
 // Structures identiques au client
 type TimingMetrics struct {
-	DNSLookupMs      float64 `json:"dns_lookup_ms"`
-	TCPConnectMs     float64 `json:"tcp_connect_ms"`
-	TLSHandshakeMs   float64 `json:"tls_handshake_ms"`
-	RequestSentMs    float64 `json:"request_sent_ms"`
-	FirstByteMs      float64 `json:"first_byte_ms"`
-	TotalResponseMs  float64 `json:"total_response_ms"`
+	DNSLookupMs     float64 `json:"dns_lookup_ms"`
+	TCPConnectMs    float64 `json:"tcp_connect_ms"`
+	TLSHandshakeMs  float64 `json:"tls_handshake_ms"`
+	RequestSentMs   float64 `json:"request_sent_ms"`
+	FirstByteMs     float64 `json:"first_byte_ms"`
+	TotalResponseMs float64 `json:"total_response_ms"`
 }
 
 type ResponseDetails struct {
@@ -21,10 +21,31 @@ type ResponseDetails struct {
 }
 
 type NetworkInfo struct {
-	LocalIP          string `json:"local_ip"`
-	RemoteIP         string `json:"remote_ip"`
-	ConnectionReused bool   `json:"connection_reused"`
-	ProtocolVersion  string `json:"protocol_version"`
+	LocalIP          string   `json:"local_ip"`
+	RemoteIP         string   `json:"remote_ip"`
+	ConnectionReused bool     `json:"connection_reused"`
+	ProtocolVersion  string   `json:"protocol_version"`
+	TLS              *TLSInfo `json:"tls,omitempty"` // HTTPS targets only
+}
+
+// TLSInfo décrit la session TLS négociée pendant TLSHandshakeMs.
+type TLSInfo struct {
+	Version     string           `json:"version"` // e.g. "TLS 1.3"
+	CipherSuite string           `json:"cipher_suite"`
+	ServerName  string           `json:"server_name,omitempty"`
+	Chain       []TLSCertificate `json:"chain"` // Peer certificates, leaf first
+}
+
+// TLSCertificate décrit un certificat de la chaîne présentée par la cible.
+type TLSCertificate struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SANs              []string  `json:"sans,omitempty"`
+	SerialNumber      string    `json:"serial_number,omitempty"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	KeyType           string    `json:"key_type"` // e.g. "RSA-2048", "ECDSA-P256"
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
 }
 
 type ErrorDetails struct {
@@ -34,24 +55,221 @@ type ErrorDetails struct {
 	RetryCount   int    `json:"retry_count"`
 }
 
+// TCPDetails décrit le résultat d'un check de connectivité TCP.
+type TCPDetails struct {
+	Host          string  `json:"host"`
+	Port          int     `json:"port"`
+	ConnectMs     float64 `json:"connect_ms"`
+	BannerRead    bool    `json:"banner_read"`              // The probe tried to read a banner
+	Banner        string  `json:"banner,omitempty"`         // First bytes sent by the server
+	ExpectString  string  `json:"expect_string,omitempty"`  // Substring the banner must contain
+	ExpectMatched *bool   `json:"expect_matched,omitempty"` // Set once the expectation is evaluated
+}
+
+// DNSDetails décrit le résultat d'un check de résolution DNS.
+type DNSDetails struct {
+	Resolver          string   `json:"resolver"`    // e.g. "9.9.9.9:53"
+	QueryName         string   `json:"query_name"`  // e.g. "example.com"
+	RecordType        string   `json:"record_type"` // A, AAAA, CNAME, MX or TXT
+	ResponseMs        float64  `json:"response_ms"`
+	Rcode             string   `json:"rcode"` // NOERROR, NXDOMAIN, SERVFAIL...
+	Answers           []string `json:"answers"`
+	Expected          []string `json:"expected,omitempty"`
+	ExpectMode        string   `json:"expect_mode,omitempty"` // "any" (default), "all" or "exact"
+	AssertionPassed   *bool    `json:"assertion_passed,omitempty"`
+	MissingExpected   []string `json:"missing_expected,omitempty"`
+	UnexpectedAnswers []string `json:"unexpected_answers,omitempty"` // Only evaluated in "exact" mode
+}
+
+// GRPCDetails décrit le résultat d'un appel grpc.health.v1.Health/Check.
+type GRPCDetails struct {
+	Target        string  `json:"target"`            // host:port
+	Service       string  `json:"service,omitempty"` // Empty for the overall server health
+	TLS           bool    `json:"tls"`
+	ConnectMs     float64 `json:"connect_ms"`
+	RPCMs         float64 `json:"rpc_ms"`
+	StatusCode    string  `json:"status_code"`    // gRPC status of the call: OK, UNAVAILABLE, DEADLINE_EXCEEDED...
+	ServingStatus string  `json:"serving_status"` // SERVING, NOT_SERVING, SERVICE_UNKNOWN or UNKNOWN
+	StatusMessage string  `json:"status_message,omitempty"`
+}
+
 type MonitoringData struct {
 	ClientID        string            `json:"client_id"`
 	Timestamp       string            `json:"timestamp"`
+	CheckType       string            `json:"check_type,omitempty"` // "http" (default), "tcp", "dns", "grpc" or "journey"
 	TargetURL       string            `json:"target_url"`
 	RequestDetails  map[string]string `json:"request_details"`
 	TimingMetrics   TimingMetrics     `json:"timing_metrics"`
-	ResponseDetails ResponseDetails   `json:"response_details"`
+	ResponseDetails ResponseDetails   `json:"response_details"` // HTTP checks only
 	NetworkInfo     NetworkInfo       `json:"network_info"`
 	ErrorDetails    ErrorDetails      `json:"error_details"`
+	TCPDetails      *TCPDetails       `json:"tcp_details,omitempty"`  // TCP checks only
+	DNSDetails      *DNSDetails       `json:"dns_details,omitempty"`  // DNS checks only
+	GRPCDetails     *GRPCDetails      `json:"grpc_details,omitempty"` // gRPC checks only
+	Steps           []StepResult      `json:"steps,omitempty"`        // Journey checks only, in execution order
+	PathTrace       *PathTrace        `json:"path_trace,omitempty"`   // Run on failure or on a schedule
+
+	AssertionResults []AssertionResult `json:"assertion_results,omitempty"`
+	IntervalSeconds  int               `json:"interval_seconds,omitempty"` // Check interval declared by the probe
+	Maintenance      bool              `json:"maintenance,omitempty"`      // Received during a maintenance window, set by the server
+	Site             string            `json:"site,omitempty"`             // Declared by the probe, or matched from its local IP by the server
+
+	// Set by the server from the client's anomaly detection strategy
+	Anomalous    bool               `json:"anomalous,omitempty"`
+	AnomalyScore float64            `json:"anomaly_score,omitempty"` // Highest deviation score of the timing phases
+	PhaseScores  map[string]float64 `json:"phase_scores,omitempty"`  // Deviation score by timing phase
+}
+
+// StepResult est le résultat d'une étape d'un parcours (check "journey").
+type StepResult struct {
+	Name             string            `json:"name"`
+	Method           string            `json:"method"`
+	URL              string            `json:"url"`             // After variable substitution
+	StartOffsetMs    float64           `json:"start_offset_ms"` // Start of the step relative to the start of the run
+	TimingMetrics    TimingMetrics     `json:"timing_metrics"`
+	ResponseDetails  ResponseDetails   `json:"response_details"`
+	ErrorDetails     ErrorDetails      `json:"error_details"`
+	AssertionResults []AssertionResult `json:"assertion_results,omitempty"`
+	Extracted        []string          `json:"extracted,omitempty"` // Names of the variables extracted by the step
+}
+
+// PathTrace est un traceroute TCP vers RemoteIP effectué par la sonde.
+type PathTrace struct {
+	Trigger         string    `json:"trigger"` // "failure" or "schedule"
+	DestinationIP   string    `json:"destination_ip"`
+	DestinationPort int       `json:"destination_port"`
+	StartedAt       time.Time `json:"started_at"`
+	Reached         bool      `json:"reached"` // The destination answered
+	Hops            []PathHop `json:"hops"`
+}
+
+// PathHop est un saut d'un traceroute. IP est vide quand aucun routeur n'a
+// répondu pour ce TTL.
+type PathHop struct {
+	TTL      int       `json:"ttl"`
+	IP       string    `json:"ip,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	RTTMs    []float64 `json:"rtt_ms,omitempty"` // One value per answered attempt
+	Lost     int       `json:"lost,omitempty"`   // Attempts without answer
+}
+
+// PathComparison confronte le chemin d'un échantillon au dernier chemin
+// mesuré lors d'un succès.
+type PathComparison struct {
+	Trace             PathTrace  `json:"trace"`
+	Success           bool       `json:"success"`
+	Timestamp         time.Time  `json:"timestamp"`
+	LastGood          *PathTrace `json:"last_good,omitempty"`
+	LastGoodAt        *time.Time `json:"last_good_at,omitempty"`
+	DivergenceTTL     int        `json:"divergence_ttl,omitempty"`      // First TTL answered by a different router, 0 if none
+	LastRespondingTTL int        `json:"last_responding_ttl,omitempty"` // Last TTL with an answer in the trace
+}
+
+// PathTraceConfig indique à la sonde quand mesurer le chemin vers la cible.
+type PathTraceConfig struct {
+	OnFailure       bool `json:"on_failure"`
+	IntervalMinutes int  `json:"interval_minutes,omitempty"` // 0 disables scheduled traces
+	MaxHops         int  `json:"max_hops,omitempty"`         // 30 by default
+	Port            int  `json:"port,omitempty"`             // Port of the target by default
+}
+
+// Assertion est une condition qu'une réponse doit remplir pour que le check
+// soit un succès.
+type Assertion struct {
+	Type     string  `json:"type"`               // status_code, body_contains, body_regex, json_path, header, max_body_size, max_latency
+	Property string  `json:"property,omitempty"` // Header name or JSON path (e.g. "$.status")
+	Value    string  `json:"value,omitempty"`    // Status list ("200,3xx,400-404"), substring, regex or expected value
+	Max      float64 `json:"max,omitempty"`      // Bytes for max_body_size, ms for max_latency
+}
+
+// AssertionResult est le résultat de l'évaluation d'une assertion, par la
+// sonde ou par le serveur.
+type AssertionResult struct {
+	Assertion
+	Passed    bool   `json:"passed"`
+	Actual    string `json:"actual,omitempty"`
+	ErrorType string `json:"error_type,omitempty"` // e.g. "assertion_status_code", set when failed
+	Message   string `json:"message,omitempty"`
+}
+
+// CheckDefinition est la configuration du check d'un client, servie à la
+// sonde et appliquée par le serveur aux échantillons reçus.
+type CheckDefinition struct {
+	TenantID    string           `json:"tenant_id"`
+	ClientID    string           `json:"client_id"`
+	CheckType   string           `json:"check_type"`
+	TargetURL   string           `json:"target_url,omitempty"`
+	Assertions  []Assertion      `json:"assertions,omitempty"`
+	Steps       []JourneyStep    `json:"steps,omitempty"` // Journey checks only
+	PathTrace   *PathTraceConfig `json:"path_trace,omitempty"`
+	Interval    int              `json:"interval_seconds,omitempty"` // Expected reporting interval, overrides the probe's
+	Tags        []string         `json:"tags,omitempty"`             // Used to target maintenance windows
+	Anomaly     *AnomalyConfig   `json:"anomaly,omitempty"`          // Adaptive (mad) by default
+	ExpectedIPs []string         `json:"expected_ips,omitempty"`     // Addresses or CIDR ranges the target may resolve to
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// AnomalyConfig choisit comment les latences anormales d'un client sont
+// détectées : seuil fixe, ou écart à une référence calculée sur son historique
+// (médiane et MAD glissantes, moyenne mobile exponentielle ou médiane de la
+// même heure de la journée).
+type AnomalyConfig struct {
+	Strategy    string  `json:"strategy"`               // fixed, mad, ewma or seasonal
+	ThresholdMs float64 `json:"threshold_ms,omitempty"` // fixed: latency limit, 1000 by default
+	MinScore    float64 `json:"min_score,omitempty"`    // Adaptive strategies: deviation score flagged, 3.5 by default
+}
+
+// PhaseBaseline est la latence habituelle d'une phase : médiane et MAD pour
+// les stratégies robustes, moyenne et écart type exponentiels pour ewma.
+type PhaseBaseline struct {
+	Samples int     `json:"samples"`
+	Median  float64 `json:"median_ms"`
+	MAD     float64 `json:"mad_ms"`
+	EWMA    float64 `json:"ewma_ms"`
+	EWMStd  float64 `json:"ewm_std_ms"`
+}
+
+// LatencyBaseline regroupe les références d'un client par phase, sur toute la
+// fenêtre et par heure locale de la journée.
+type LatencyBaseline struct {
+	ClientID   string                       `json:"client_id"`
+	ComputedAt time.Time                    `json:"computed_at"`
+	Phases     map[string]PhaseBaseline     `json:"phases"`
+	Hourly     [24]map[string]PhaseBaseline `json:"hourly"`
+}
+
+// JourneyStep est une requête HTTP d'un parcours. URL, en-têtes et corps
+// peuvent référencer les variables extraites des étapes précédentes avec
+// ${nom}. La sonde conserve les cookies d'une étape à l'autre pendant un run.
+type JourneyStep struct {
+	Name       string            `json:"name"`
+	Method     string            `json:"method,omitempty"` // GET by default
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Extract    []VariableExtract `json:"extract,omitempty"`
+	Assertions []Assertion       `json:"assertions,omitempty"`
+}
+
+// VariableExtract extrait une variable de la réponse d'une étape.
+type VariableExtract struct {
+	Name       string `json:"name"`
+	Source     string `json:"source"`     // json_path, header or regex
+	Expression string `json:"expression"` // JSON path, header name or regex with one capture group
 }
 
 // Structure pour l'affichage
 type ClientStatus struct {
 	ID              string
 	Name            string
+	CheckType       string
 	TargetURL       string
 	LastSeen        time.Time
-	IsOnline        bool
+	IsOnline        bool   // Reporting on time or slightly late
+	State           string // online, late, stale or offline
+	Interval        int    // Expected seconds between two results
+	IntervalSource  string // check, probe, learned or default
+	MissedReports   int    // Results missed since LastSeen
 	LastLatency     float64
 	LastStatusCode  int
 	SuccessRate     float64
@@ -59,6 +277,32 @@ type ClientStatus struct {
 	LastErrorTime   time.Time
 	TimingBreakdown TimingMetrics
 	NetworkInfo     NetworkInfo
+	TCPDetails      *TCPDetails
+	DNSDetails      *DNSDetails
+	GRPCDetails     *GRPCDetails
+	Steps           []StepResult
+	Probe           *ProbeHeartbeat // Last heartbeat of the probe, nil if it never sent one
+	ProbeOnline     bool            // The probe itself is alive, whatever its check results
+	Tags            []string
+	Site            string
+	InMaintenance   bool       // A maintenance window covering the client is running
+	SilencedUntil   *time.Time // Alerts of the client are silenced until then
+}
+
+// ProbeHeartbeat est l'état qu'une sonde rapporte périodiquement,
+// indépendamment du résultat de ses checks.
+type ProbeHeartbeat struct {
+	ClientID        string    `json:"client_id"`
+	ProbeVersion    string    `json:"probe_version"`
+	Hostname        string    `json:"hostname"`
+	OS              string    `json:"os"`
+	Arch            string    `json:"arch"`
+	UptimeSeconds   int64     `json:"uptime_seconds"`
+	IntervalSeconds int       `json:"interval_seconds"` // Heartbeat period, 30 by default
+	Checks          []string  `json:"checks"`           // Checks configured on the probe
+	QueueDepth      int       `json:"queue_depth"`      // Results waiting to be sent
+	ReceivedAt      time.Time `json:"received_at"`
+	RemoteAddr      string    `json:"remote_addr"`
 }
 
 type DashboardData struct {
@@ -71,23 +315,401 @@ type DashboardData struct {
 
 // APIDashboardData structure pour les données du tableau de bord envoyées via API
 type APIDashboardData struct {
-	OnlineCount    int          `json:"online_count"`
-	OfflineCount   int          `json:"offline_count"`
-	TotalCount     int          `json:"total_count"`
-	AverageLatency float64      `json:"average_latency"`
-	Clients        []ClientStatus `json:"clients"`
-	SelectedClient *ClientStatus `json:"selected_client,omitempty"` // Omit if null
-	ClientHistory  []MonitoringData `json:"client_history,omitempty"`
-	ClientAnomalies []MonitoringData `json:"client_anomalies,omitempty"`
+	OnlineCount       int              `json:"online_count"`
+	OfflineCount      int              `json:"offline_count"`
+	TotalCount        int              `json:"total_count"`
+	AverageLatency    float64          `json:"average_latency"`
+	Clients           []ClientStatus   `json:"clients"`
+	SelectedClient    *ClientStatus    `json:"selected_client,omitempty"` // Omit if null
+	ClientHistory     []MonitoringData `json:"client_history,omitempty"`
+	ClientAnomalies   []MonitoringData `json:"client_anomalies,omitempty"`
+	ClientGaps        []Gap            `json:"client_gaps,omitempty"`
+	ClientPhaseShifts []PhaseShift     `json:"client_phase_shifts,omitempty"`
+	ClientRemoteIPs   []RemoteIP       `json:"client_remote_ips,omitempty"`
+	ClientIPChanges   []IPChange       `json:"client_ip_changes,omitempty"`
+	ClientProtocols   *ProtocolReport  `json:"client_protocols,omitempty"`
+}
+
+// Gap est une période pendant laquelle un client n'a envoyé aucun résultat
+// au-delà de son intervalle attendu.
+type Gap struct {
+	Start           time.Time `json:"start"` // Last sample before the gap
+	End             time.Time `json:"end"`   // First sample after the gap, or now if ongoing
+	DurationSeconds float64   `json:"duration_seconds"`
+	MissedSamples   int       `json:"missed_samples"`
+	Ongoing         bool      `json:"ongoing"`
+}
+
+// GapReport regroupe les trous d'un client sur une période.
+type GapReport struct {
+	ClientID        string    `json:"client_id"`
+	IntervalSeconds int       `json:"interval_seconds"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Samples         int       `json:"samples"`
+	MissedSamples   int       `json:"missed_samples"`
+	Coverage        float64   `json:"coverage"` // Percentage of expected samples received
+	Gaps            []Gap     `json:"gaps"`
 }
 
 type HistoryFilterOptions struct {
-	ClientID      string
-	Duration      time.Duration
-	SortBy        string // e.g., "timestamp", "latency", "status_code"
-	SortOrder     string // "asc" or "desc"
-	Limit         int    // Max number of results
-	StatusFilter  string // "success", "error", "all"
-	MinLatency    float64
-	MaxLatency    float64
+	TenantID     string
+	ClientID     string
+	Duration     time.Duration
+	SortBy       string // e.g., "timestamp", "latency", "status_code"
+	SortOrder    string // "asc" or "desc"
+	Limit        int    // Max number of results
+	StatusFilter string // "success", "error", "all"
+	MinLatency   float64
+	MaxLatency   float64
+}
+
+// ProbeToken décrit un jeton d'API de sonde. Seul le hachage du jeton est
+// conservé ; la valeur en clair n'est renvoyée qu'une fois, à l'émission.
+type ProbeToken struct {
+	ID         int64      `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	ClientID   string     `json:"client_id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Token      string     `json:"token,omitempty"` // Only set in the issuance response
+}
+
+// User est un compte d'accès au tableau de bord.
+type User struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`      // viewer, operator, admin
+	TenantID  string    `json:"tenant_id"` // Empty for users spanning all tenants
+	CreatedAt time.Time `json:"created_at"`
+	Disabled  bool      `json:"disabled"`
+}
+
+// AuditEntry est une entrée du journal des actions d'administration.
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target"`
+	Details    string    `json:"details"`
+	RemoteAddr string    `json:"remote_addr"`
+}
+
+// Tenant est une organisation propriétaire de clients, jetons et utilisateurs.
+type Tenant struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	RetentionDays int       `json:"retention_days"` // History kept for this many days
+	MaxClients    int       `json:"max_clients"`    // 0 means unlimited
+	GapPolicy     string    `json:"gap_policy"`     // How gaps count in success rates: ignore or down
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TargetCertificate est la chaîne de certificats actuellement présentée par
+// la cible d'un client, avec la précédente si elle a changé.
+type TargetCertificate struct {
+	TenantID         string           `json:"tenant_id"`
+	ClientID         string           `json:"client_id"`
+	TargetURL        string           `json:"target_url"`
+	TLSVersion       string           `json:"tls_version"`
+	CipherSuite      string           `json:"cipher_suite"`
+	Chain            []TLSCertificate `json:"chain"`
+	ChainFingerprint string           `json:"chain_fingerprint"`
+	NotAfter         time.Time        `json:"not_after"` // Leaf expiry
+	DaysRemaining    int              `json:"days_remaining"`
+	FirstSeen        time.Time        `json:"first_seen"`
+	LastSeen         time.Time        `json:"last_seen"`
+	PreviousChain    []TLSCertificate `json:"previous_chain,omitempty"`
+	ChangedAt        *time.Time       `json:"changed_at,omitempty"`
+	UnexpectedChange bool             `json:"unexpected_change"` // The last change was not a renewal
+}
+
+// AlertRule est une règle d'alerte évaluée périodiquement par le serveur.
+type AlertRule struct {
+	ID        int64              `json:"id"`
+	TenantID  string             `json:"tenant_id"`
+	Name      string             `json:"name"`
+	Type      string             `json:"type"`      // e.g. "cert_expiry", "cert_chain_changed"
+	ClientID  string             `json:"client_id"` // Empty for every client of the tenant
+	Params    map[string]float64 `json:"params,omitempty"`
+	Enabled   bool               `json:"enabled"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// Alert est le déclenchement d'une règle pour une cible donnée.
+type Alert struct {
+	ID         int64      `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	RuleID     int64      `json:"rule_id"`
+	RuleName   string     `json:"rule_name"`
+	Type       string     `json:"type"`
+	ClientID   string     `json:"client_id"`
+	Target     string     `json:"target"`
+	Message    string     `json:"message"`
+	State      string     `json:"state"` // firing or resolved
+	StartedAt  time.Time  `json:"started_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// MaintenanceWindow est une période de maintenance planifiée, exclue des
+// calculs de disponibilité.
+type MaintenanceWindow struct {
+	ID              int64     `json:"id"`
+	TenantID        string    `json:"tenant_id"`
+	ClientID        string    `json:"client_id,omitempty"`  // Restricts the window to a client
+	TargetURL       string    `json:"target_url,omitempty"` // Restricts the window to the clients of a target
+	Tag             string    `json:"tag,omitempty"`        // Restricts the window to the clients with this tag
+	Start           time.Time `json:"start"`                // One-off window, or first day of a recurring one
+	End             time.Time `json:"end"`                  // One-off window, or last day of a recurring one
+	Cron            string    `json:"cron,omitempty"`       // Start times of a recurring window
+	DurationMinutes int       `json:"duration_minutes,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+	CreatedBy       string    `json:"created_by,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Silence suspend les alertes d'un client ou d'une règle jusqu'à son expiration.
+type Silence struct {
+	ID        int64     `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	ClientID  string    `json:"client_id,omitempty"` // Empty for every client
+	RuleID    int64     `json:"rule_id,omitempty"`   // 0 for every rule
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ClientUptime est la disponibilité d'un client sur une période de rapport.
+type ClientUptime struct {
+	ClientID           string  `json:"client_id"`
+	TargetURL          string  `json:"target_url"`
+	Samples            int     `json:"samples"`
+	Uptime             float64 `json:"uptime"` // Percentage of the monitored time the target was up
+	MonitoredSeconds   float64 `json:"monitored_seconds"`
+	DowntimeMinutes    float64 `json:"downtime_minutes"`
+	MaintenanceMinutes float64 `json:"maintenance_minutes"`
+	Incidents          int     `json:"incidents"`
+	MTTRSeconds        float64 `json:"mttr_seconds"` // Mean time to recovery
+	MTBFSeconds        float64 `json:"mtbf_seconds"` // Mean time between failures
+	SLAMet             bool    `json:"sla_met"`
+}
+
+// UptimeReport regroupe la disponibilité des clients d'un tenant sur une période.
+type UptimeReport struct {
+	TenantID  string    `json:"tenant_id"`
+	Period    string    `json:"period"` // day, week, month or custom
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	GapPolicy string    `json:"gap_policy"`
+	SLATarget float64   `json:"sla_target,omitempty"`
+	// Start of the history still kept when the report starts earlier: the
+	// availability only covers the period from there on
+	HistoryFrom *time.Time     `json:"history_from,omitempty"`
+	Clients     []ClientUptime `json:"clients"`
+}
+
+// SLO est un objectif de niveau de service déclaré pour la cible d'un client.
+type SLO struct {
+	ID         int64     `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	ClientID   string    `json:"client_id"`
+	Name       string    `json:"name"`
+	Objective  float64   `json:"objective"`            // Percentage of good checks, e.g. 99.9
+	LatencyMs  float64   `json:"latency_ms,omitempty"` // Good checks must also be this fast, 0 for no limit
+	WindowDays int       `json:"window_days"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BurnRate est la vitesse de consommation du budget d'erreur sur une fenêtre.
+type BurnRate struct {
+	Window string  `json:"window"`
+	Rate   float64 `json:"rate"` // 1 consumes the whole budget in exactly the SLO window
+	Events int     `json:"events"`
+}
+
+// SLOStatus est l'état d'un SLO : indicateur, budget d'erreur restant et
+// vitesses de consommation.
+type SLOStatus struct {
+	SLO
+	EffectiveWindowDays int        `json:"effective_window_days"` // Window computed over, capped at the history retention
+	TotalEvents         int        `json:"total_events"`
+	GoodEvents          int        `json:"good_events"`
+	SLI                 float64    `json:"sli"`              // Percentage of good checks over the window
+	BudgetRemaining     float64    `json:"budget_remaining"` // Share of the error budget left, negative when overspent
+	BurnRates           []BurnRate `json:"burn_rates"`
+	ExhaustionAt        *time.Time `json:"exhaustion_at,omitempty"` // Forecast at the current burn rate
+}
+
+// Incident regroupe les échantillons consécutifs en échec ou trop lents
+// d'une même cible, quel que soit le client qui les a remontés.
+type Incident struct {
+	ID              int64          `json:"id"`
+	TenantID        string         `json:"tenant_id"`
+	TargetURL       string         `json:"target_url"`
+	State           string         `json:"state"` // open or closed
+	StartedAt       time.Time      `json:"started_at"`
+	LastBadAt       time.Time      `json:"last_bad_at"`
+	EndedAt         *time.Time     `json:"ended_at,omitempty"`
+	DurationSeconds float64        `json:"duration_seconds"` // Up to now while open
+	Samples         int            `json:"samples"`          // Bad samples
+	PeakLatencyMs   float64        `json:"peak_latency_ms"`
+	DominantError   string         `json:"dominant_error"`
+	ErrorCounts     map[string]int `json:"error_counts"`
+	AffectedClients []string       `json:"affected_clients"`
+	Classification  string         `json:"classification"` // target, probe or unknown, from the other clients and targets
+	RootCause       string         `json:"root_cause,omitempty"`
+	Notes           []IncidentNote `json:"notes,omitempty"`
+}
+
+// IncidentNote est une annotation manuelle d'un incident.
+type IncidentNote struct {
+	ID         int64     `json:"id"`
+	IncidentID int64     `json:"incident_id"`
+	Author     string    `json:"author"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PhaseShift est un changement durable de la durée d'une phase de la requête
+// d'un client (DNS, TCP, TLS, premier octet ou téléchargement), détecté sur
+// ses derniers échantillons.
+type PhaseShift struct {
+	ID         int64     `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	ClientID   string    `json:"client_id"`
+	Phase      string    `json:"phase"`      // dns, tcp, tls, first_byte or download
+	ChangedAt  time.Time `json:"changed_at"` // First sample at the new level
+	DetectedAt time.Time `json:"detected_at"`
+	BeforeMs   float64   `json:"before_ms"` // Median duration before the change
+	AfterMs    float64   `json:"after_ms"`  // Median duration since
+	Ratio      float64   `json:"ratio"`     // AfterMs / BeforeMs
+	Message    string    `json:"message"`
+}
+
+// HealthMatrix est l'état récent de chaque couple client × cible d'un
+// tenant, avec les cibles et les sondes en panne déduites de leur corrélation.
+type HealthMatrix struct {
+	Since       time.Time    `json:"since"`
+	Clients     []string     `json:"clients"`
+	Targets     []string     `json:"targets"`
+	Cells       []MatrixCell `json:"cells"`        // Pairs with samples in the period only
+	DownTargets []string     `json:"down_targets"` // Failing from most of the clients probing them
+	DownProbes  []string     `json:"down_probes"`  // Failing while their targets answer elsewhere, or failing every target
+}
+
+// MatrixCell est l'état d'un client face à une cible sur la période.
+type MatrixCell struct {
+	ClientID      string    `json:"client_id"`
+	TargetURL     string    `json:"target_url"`
+	State         string    `json:"state"` // ok, degraded or failing
+	Samples       int       `json:"samples"`
+	Failures      int       `json:"failures"` // Failed or abnormally slow samples
+	LastLatencyMs float64   `json:"last_latency_ms"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// Site est un emplacement de sondes : bureau, datacenter ou région cloud. Les
+// clients y sont rattachés par le site que déclare leur sonde ou, à défaut,
+// par leur adresse IP locale.
+type Site struct {
+	Name      string   `json:"name"`
+	TenantID  string   `json:"tenant_id"`
+	Region    string   `json:"region,omitempty"`
+	Latitude  float64  `json:"latitude,omitempty"`
+	Longitude float64  `json:"longitude,omitempty"`
+	Subnets   []string `json:"subnets,omitempty"` // CIDRs matched against the local IP of the probes
+}
+
+// SiteStats résume les échantillons des clients d'un site sur une période.
+type SiteStats struct {
+	Site            string  `json:"site"` // Empty for the clients without a site
+	Samples         int     `json:"samples"`
+	SuccessRate     float64 `json:"success_rate"`               // Percent
+	AvgLatencyMs    float64 `json:"avg_latency_ms"`             // Successful samples only
+	RelativeLatency float64 `json:"relative_latency,omitempty"` // Against the fastest site, per target only
+}
+
+// SiteSummary est l'état d'un site : ses clients et leurs résultats.
+type SiteSummary struct {
+	Site    Site      `json:"site"`
+	Stats   SiteStats `json:"stats"`
+	Clients []string  `json:"clients"`
+	Online  int       `json:"online"` // Clients reporting on time
+}
+
+// TargetSiteComparison compare la latence d'une cible vue depuis chaque site.
+type TargetSiteComparison struct {
+	TargetURL   string      `json:"target_url"`
+	Sites       []SiteStats `json:"sites"`
+	FastestSite string      `json:"fastest_site"`
+}
+
+// SiteReport agrège la santé et la latence d'un tenant par site.
+type SiteReport struct {
+	Since   time.Time              `json:"since"`
+	Sites   []SiteSummary          `json:"sites"`
+	Targets []TargetSiteComparison `json:"targets"`
+}
+
+// RemoteIP est une adresse vers laquelle la cible d'un client a été résolue.
+type RemoteIP struct {
+	TenantID  string    `json:"tenant_id"`
+	ClientID  string    `json:"client_id"`
+	TargetURL string    `json:"target_url"`
+	RemoteIP  string    `json:"remote_ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Samples   int       `json:"samples"`
+	Current   bool      `json:"current"`  // Address of the last sample
+	Expected  bool      `json:"expected"` // Within the expected_ips of the check, or none defined
+}
+
+// IPChange est un changement de l'adresse résolue de la cible d'un client,
+// avec la latence et le taux d'erreur autour du changement.
+type IPChange struct {
+	ID              int64     `json:"id"`
+	TenantID        string    `json:"tenant_id"`
+	ClientID        string    `json:"client_id"`
+	TargetURL       string    `json:"target_url"`
+	PreviousIP      string    `json:"previous_ip"`
+	NewIP           string    `json:"new_ip"`
+	ChangedAt       time.Time `json:"changed_at"`
+	Unexpected      bool      `json:"unexpected"` // New address never seen before, or outside expected_ips
+	BeforeLatencyMs float64   `json:"before_latency_ms"`
+	AfterLatencyMs  float64   `json:"after_latency_ms"`
+	BeforeErrorRate float64   `json:"before_error_rate"` // Percent
+	AfterErrorRate  float64   `json:"after_error_rate"`  // Percent
+	Degraded        bool      `json:"degraded"`          // Latency or errors went up after the change
+	Message         string    `json:"message"`
+}
+
+// ProtocolStats compte les échantillons d'un client par version du protocole
+// HTTP négociée.
+type ProtocolStats struct {
+	Protocol     string  `json:"protocol"` // As reported by the probe, e.g. "HTTP/2.0"
+	Samples      int     `json:"samples"`
+	Share        float64 `json:"share"`          // Percent of the samples
+	AvgLatencyMs float64 `json:"avg_latency_ms"` // Successful samples only
+}
+
+// ProtocolReport répartit les échantillons HTTP d'un client par protocole et
+// par connexion neuve ou réutilisée, et signale un refus du keep-alive ou un
+// protocole rétrogradé sur la dernière heure.
+type ProtocolReport struct {
+	ClientID         string          `json:"client_id"`
+	Since            time.Time       `json:"since"`
+	Samples          int             `json:"samples"`
+	Protocols        []ProtocolStats `json:"protocols"`          // Most used first
+	ReuseRatio       float64         `json:"reuse_ratio"`        // Percent of the samples on a reused connection
+	FreshLatencyMs   float64         `json:"fresh_latency_ms"`   // Successful samples on a new connection
+	ReusedLatencyMs  float64         `json:"reused_latency_ms"`  // Successful samples on a reused connection
+	RecentReuseRatio float64         `json:"recent_reuse_ratio"` // Last hour
+	RecentProtocol   string          `json:"recent_protocol"`    // Most used in the last hour
+	Warnings         []string        `json:"warnings,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+var errUnknownPathTrace = errors.New("aucun traceroute pour cet échantillon")
+
+// storePathTrace records the path trace attached to a sample, if any.
+func (s *Server) storePathTrace(tenantID string, data MonitoringData) error {
+	trace := data.PathTrace
+	if trace == nil || len(trace.Hops) == 0 {
+		return nil
+	}
+	traceJSON, _ := json.Marshal(trace)
+
+	_, err := s.db.Exec(`
+		INSERT INTO path_traces (tenant_id, client_id, timestamp, sample_timestamp, success, destination_ip, trace)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		tenantID, data.ClientID, time.Now(), data.Timestamp, !data.ErrorDetails.HasError, trace.DestinationIP, string(traceJSON))
+	return err
+}
+
+// getPathComparison returns the path trace of a client's sample, identified by
+// the timestamp sent by the probe, with the last trace taken during a
+// successful sample before it.
+func (s *Server) getPathComparison(tenantID, clientID, sampleTimestamp string) (PathComparison, error) {
+	var cmp PathComparison
+	var traceJSON string
+	err := s.db.QueryRow(`
+		SELECT timestamp, success, trace FROM path_traces
+		WHERE tenant_id = ? AND client_id = ? AND sample_timestamp = ?
+		ORDER BY timestamp DESC LIMIT 1`,
+		tenantID, clientID, sampleTimestamp).Scan(&cmp.Timestamp, &cmp.Success, &traceJSON)
+	if err == sql.ErrNoRows {
+		return cmp, errUnknownPathTrace
+	}
+	if err != nil {
+		return cmp, err
+	}
+	if err := json.Unmarshal([]byte(traceJSON), &cmp.Trace); err != nil {
+		return cmp, err
+	}
+	for _, hop := range cmp.Trace.Hops {
+		if hop.IP != "" && hop.TTL > cmp.LastRespondingTTL {
+			cmp.LastRespondingTTL = hop.TTL
+		}
+	}
+
+	var goodAt time.Time
+	var goodJSON string
+	err = s.db.QueryRow(`
+		SELECT timestamp, trace FROM path_traces
+		WHERE tenant_id = ? AND client_id = ? AND success = 1 AND timestamp < ?
+		ORDER BY timestamp DESC LIMIT 1`,
+		tenantID, clientID, cmp.Timestamp).Scan(&goodAt, &goodJSON)
+	if err == sql.ErrNoRows {
+		return cmp, nil
+	}
+	if err != nil {
+		return cmp, err
+	}
+	var good PathTrace
+	if err := json.Unmarshal([]byte(goodJSON), &good); err != nil {
+		log.Printf("Erreur de décodage du dernier traceroute réussi du client %s: %v", clientID, err)
+		return cmp, nil
+	}
+	cmp.LastGood = &good
+	cmp.LastGoodAt = &goodAt
+	cmp.DivergenceTTL = pathDivergence(good, cmp.Trace)
+	return cmp, nil
+}
+
+// pathDivergence returns the first TTL at which both traces got an answer
+// from different routers, or at which the trace stopped answering while the
+// good path went on. It returns 0 when the paths agree.
+func pathDivergence(good, trace PathTrace) int {
+	goodHops := make(map[int]string, len(good.Hops))
+	for _, hop := range good.Hops {
+		goodHops[hop.TTL] = hop.IP
+	}
+	for _, hop := range trace.Hops {
+		goodIP, ok := goodHops[hop.TTL]
+		if !ok || goodIP == "" {
+			continue
+		}
+		if hop.IP != goodIP {
+			return hop.TTL
+		}
+	}
+	return 0
+}
+
+// HandlePathTraces returns the path trace of a sample compared with the last
+// good path (GET ?client_id=&timestamp=, timestamp as sent by the probe).
+func (s *Server) HandlePathTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID := r.URL.Query().Get("client_id")
+	timestamp := r.URL.Query().Get("timestamp")
+	if clientID == "" || timestamp == "" {
+		http.Error(w, "client_id et timestamp requis", http.StatusBadRequest)
+		return
+	}
+
+	cmp, err := s.getPathComparison(s.requestTenant(r), clientID, timestamp)
+	if err == errUnknownPathTrace {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur de récupération du traceroute du client %s: %v", clientID, err)
+		http.Error(w, "Erreur de récupération du traceroute", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, cmp)
+}
\ No newline at end of file
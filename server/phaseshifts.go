@@ -0,0 +1,285 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	shiftWindowSamples = 120             // Recent samples searched for a change point
+	shiftMinSegment    = 10              // Samples required on each side of the change
+	shiftMinScore      = 6.0             // t-statistic of the change on log durations
+	shiftMinRatio      = 1.5             // Relative change of the median reported
+	shiftMinDeltaMs    = 5.0             // Absolute change of the median reported
+	shiftCheckInterval = 1 * time.Minute // Detection runs at most this often per client
+	shiftSameLevel     = 1.25            // A new level within this factor of the last reported one is the same shift
+)
+
+// shiftPhases are the timing phases analysed for regressions, as durations:
+// the probe reports first byte and total times from the start of the request.
+var shiftPhases = []struct {
+	name, label string
+	value       func(TimingMetrics) float64
+}{
+	{"dns", "Résolution DNS", func(t TimingMetrics) float64 { return t.DNSLookupMs }},
+	{"tcp", "Connexion TCP", func(t TimingMetrics) float64 { return t.TCPConnectMs }},
+	{"tls", "Poignée de main TLS", func(t TimingMetrics) float64 { return t.TLSHandshakeMs }},
+	{"first_byte", "Attente du premier octet", func(t TimingMetrics) float64 {
+		return t.FirstByteMs - t.DNSLookupMs - t.TCPConnectMs - t.TLSHandshakeMs
+	}},
+	{"download", "Téléchargement", func(t TimingMetrics) float64 { return t.TotalResponseMs - t.FirstByteMs }},
+}
+
+// phaseLabel returns the display name of a timing phase.
+func phaseLabel(phase string) string {
+	for _, p := range shiftPhases {
+		if p.name == phase {
+			return p.label
+		}
+	}
+	return phase
+}
+
+// describe sets the message of a shift, e.g. "Poignée de main TLS de
+// client-1 : ×3.0 (12 → 36 ms) depuis le 18/10 14:05".
+func (ps *PhaseShift) describe() {
+	ps.Message = fmt.Sprintf("%s de %s : ×%.1f (%.0f → %.0f ms) depuis le %s",
+		phaseLabel(ps.Phase), ps.ClientID, ps.Ratio, ps.BeforeMs, ps.AfterMs, ps.ChangedAt.Local().Format("02/01 15:04"))
+}
+
+// findChangePoint returns the index splitting durations, in chronological
+// order, into the two segments whose mean log durations differ the most, with
+// the t-statistic of that difference.
+func findChangePoint(values []float64) (int, float64) {
+	n := len(values)
+	sum := make([]float64, n+1)
+	sumSq := make([]float64, n+1)
+	for i, v := range values {
+		l := math.Log(v)
+		sum[i+1] = sum[i] + l
+		sumSq[i+1] = sumSq[i] + l*l
+	}
+
+	best, bestScore := -1, 0.0
+	for k := shiftMinSegment; k <= n-shiftMinSegment; k++ {
+		n1, n2 := float64(k), float64(n-k)
+		m1 := sum[k] / n1
+		m2 := (sum[n] - sum[k]) / n2
+		ss1 := sumSq[k] - n1*m1*m1
+		ss2 := sumSq[n] - sumSq[k] - n2*m2*m2
+		// Pooled variance, floored at ~3% of jitter so that flat series do not
+		// turn rounding into a change
+		variance := math.Max((ss1+ss2)/float64(n-2), 1e-3)
+		score := math.Abs(m1-m2) / math.Sqrt(variance*(1/n1+1/n2))
+		if score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best, bestScore
+}
+
+// measureShift finds the change point of durations in chronological order and
+// returns it with the median durations before and after, if the change is
+// significant and large enough to be reported.
+func measureShift(values []float64) (k int, beforeMs, afterMs float64, ok bool) {
+	if len(values) < 2*shiftMinSegment {
+		return -1, 0, 0, false
+	}
+	k, score := findChangePoint(values)
+	if k < 0 || score < shiftMinScore {
+		return -1, 0, 0, false
+	}
+	before := append([]float64(nil), values[:k]...)
+	after := append([]float64(nil), values[k:]...)
+	sort.Float64s(before)
+	sort.Float64s(after)
+	beforeMs, afterMs = median(before), median(after)
+	ratio := afterMs / beforeMs
+	if math.Abs(afterMs-beforeMs) < shiftMinDeltaMs || (ratio < shiftMinRatio && ratio > 1/shiftMinRatio) {
+		return -1, 0, 0, false
+	}
+	return k, beforeMs, afterMs, true
+}
+
+// sameShiftLevel reports whether a new level is within shiftSameLevel of the
+// level of the last reported shift, i.e. the same change found again.
+func sameShiftLevel(afterMs, lastAfterMs float64) bool {
+	if lastAfterMs <= 0 {
+		return false
+	}
+	r := afterMs / lastAfterMs
+	return r < shiftSameLevel && r > 1/shiftSameLevel
+}
+
+// detectPhaseShifts looks for a lasting change in the duration of each timing
+// phase over the recent successful samples of a client, and records the ones
+// not reported yet. It runs at most every shiftCheckInterval per client.
+func (s *Server) detectPhaseShifts(tenantID, clientID string) error {
+	key := tenantID + "/" + clientID
+	s.shiftMu.Lock()
+	if time.Since(s.shiftChecks[key]) < shiftCheckInterval {
+		s.shiftMu.Unlock()
+		return nil
+	}
+	s.shiftChecks[key] = time.Now()
+	s.shiftMu.Unlock()
+
+	rows, err := s.db.Query(`
+		SELECT timestamp, data FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND success = 1 AND maintenance = 0
+		ORDER BY timestamp DESC
+		LIMIT ?`,
+		tenantID, clientID, shiftWindowSamples)
+	if err != nil {
+		return err
+	}
+	type sample struct {
+		at     time.Time
+		timing TimingMetrics
+	}
+	var samples []sample
+	for rows.Next() {
+		var ts time.Time
+		var dataStr string
+		if err := rows.Scan(&ts, &dataStr); err != nil {
+			log.Printf("Erreur de scan de l'historique du client %s: %v", clientID, err)
+			continue
+		}
+		var data MonitoringData
+		if err := json.Unmarshal([]byte(dataStr), &data); err != nil {
+			continue
+		}
+		samples = append(samples, sample{ts, data.TimingMetrics})
+	}
+	rows.Close()
+
+	for _, phase := range shiftPhases {
+		// Oldest first; phases a sample did not go through are left out
+		var values []float64
+		var times []time.Time
+		for i := len(samples) - 1; i >= 0; i-- {
+			if v := phase.value(samples[i].timing); v > 0 {
+				values = append(values, v)
+				times = append(times, samples[i].at)
+			}
+		}
+		k, beforeMs, afterMs, ok := measureShift(values)
+		if !ok {
+			continue
+		}
+		shift := PhaseShift{
+			TenantID:   tenantID,
+			ClientID:   clientID,
+			Phase:      phase.name,
+			ChangedAt:  times[k],
+			DetectedAt: time.Now(),
+			BeforeMs:   beforeMs,
+			AfterMs:    afterMs,
+		}
+		shift.Ratio = shift.AfterMs / shift.BeforeMs
+
+		// The same change is found again as long as it stays in the window
+		var lastAfter float64
+		err := s.db.QueryRow(`
+			SELECT after_ms FROM phase_shifts
+			WHERE tenant_id = ? AND client_id = ? AND phase = ?
+			ORDER BY changed_at DESC LIMIT 1`,
+			tenantID, clientID, phase.name).Scan(&lastAfter)
+		if err == nil && sameShiftLevel(shift.AfterMs, lastAfter) {
+			continue
+		}
+
+		if _, err := s.db.Exec(`
+			INSERT INTO phase_shifts (tenant_id, client_id, phase, changed_at, detected_at, before_ms, after_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			shift.TenantID, shift.ClientID, shift.Phase, shift.ChangedAt, shift.DetectedAt, shift.BeforeMs, shift.AfterMs); err != nil {
+			return err
+		}
+		shift.describe()
+		log.Printf("📈 %s", shift.Message)
+	}
+	return nil
+}
+
+// listPhaseShifts returns the phase shifts of a tenant that happened since a
+// given time, most recent first, for one client or all ("").
+func (s *Server) listPhaseShifts(tenantID, clientID string, since time.Time) ([]PhaseShift, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, client_id, phase, changed_at, detected_at, before_ms, after_ms
+		FROM phase_shifts
+		WHERE tenant_id = ? AND (? = '' OR client_id = ?) AND changed_at > ?
+		ORDER BY changed_at DESC`,
+		tenantID, clientID, clientID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := []PhaseShift{}
+	for rows.Next() {
+		var ps PhaseShift
+		if err := rows.Scan(&ps.ID, &ps.TenantID, &ps.ClientID, &ps.Phase, &ps.ChangedAt, &ps.DetectedAt, &ps.BeforeMs, &ps.AfterMs); err != nil {
+			log.Printf("Erreur de scan des ruptures de phase: %v", err)
+			continue
+		}
+		if ps.BeforeMs > 0 {
+			ps.Ratio = ps.AfterMs / ps.BeforeMs
+		}
+		ps.describe()
+		shifts = append(shifts, ps)
+	}
+	return shifts, nil
+}
+
+// evaluatePhaseShift finds the timing phases whose last shift slowed them
+// down by at least "min_ratio" (2 by default) in the last "hold_hours" (24).
+// The alert resolves once a later shift brings the phase back.
+func (s *Server) evaluatePhaseShift(rule AlertRule) ([]alertFinding, error) {
+	minRatio := rule.param("min_ratio", 2)
+	hold := time.Duration(rule.param("hold_hours", 24) * float64(time.Hour))
+	shifts, err := s.listPhaseShifts(rule.TenantID, rule.ClientID, time.Now().Add(-hold))
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []alertFinding
+	seen := make(map[string]bool)
+	for _, ps := range shifts {
+		key := ps.ClientID + "\x00" + ps.Phase
+		if seen[key] {
+			continue // Only the last shift of a phase counts
+		}
+		seen[key] = true
+		if ps.Ratio >= minRatio {
+			findings = append(findings, alertFinding{ClientID: ps.ClientID, Target: ps.Phase, Message: ps.Message})
+		}
+	}
+	return findings, nil
+}
+
+// HandlePhaseShifts lists the phase shifts of the tenant (GET
+// ?client_id=&days=, 7 days by default).
+func (s *Server) HandlePhaseShifts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	days := 7
+	if d, err := strconv.Atoi(query.Get("days")); err == nil && d > 0 {
+		days = d
+	}
+	shifts, err := s.listPhaseShifts(s.requestTenant(r), query.Get("client_id"), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("Erreur de récupération des ruptures de phase: %v", err)
+		http.Error(w, "Erreur de récupération des ruptures de phase", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, shifts)
+}
\ No newline at end of file
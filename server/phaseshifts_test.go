@@ -0,0 +1,112 @@
+package server
+
+import (
+	"math"
+	"testing"
+)
+
+// stepSeries returns n durations at before then, from index k, at after, with
+// a small deterministic jitter.
+func stepSeries(n, k int, before, after float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		level := before
+		if i >= k {
+			level = after
+		}
+		values[i] = level * (1 + 0.01*float64(i%3-1))
+	}
+	return values
+}
+
+// repeat returns n durations of v.
+func repeat(v float64, n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = v
+	}
+	return values
+}
+
+func TestFindChangePoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		wantK    int
+		minScore float64
+		maxScore float64
+	}{
+		{name: "step up", values: stepSeries(60, 25, 20, 60), wantK: 25, minScore: shiftMinScore},
+		{name: "step down", values: stepSeries(60, 40, 80, 20), wantK: 40, minScore: shiftMinScore},
+		{name: "step at the first allowed index", values: stepSeries(40, shiftMinSegment, 20, 60), wantK: shiftMinSegment, minScore: shiftMinScore},
+		{name: "step at the last allowed index", values: stepSeries(40, 40-shiftMinSegment, 20, 60), wantK: 40 - shiftMinSegment, minScore: shiftMinScore},
+		{name: "jittered flat series", values: stepSeries(60, 60, 20, 20), wantK: -1, maxScore: shiftMinScore},
+		// Without the variance floor, identical values on each side would
+		// turn a 1% rounding step into an infinite score
+		{name: "rounding step", values: append(repeat(20, 20), repeat(20.2, 20)...), wantK: 20, maxScore: shiftMinScore},
+	}
+
+	for _, tt := range tests {
+		k, score := findChangePoint(tt.values)
+		if tt.wantK >= 0 && k != tt.wantK {
+			t.Errorf("%s: change point = %d, want %d", tt.name, k, tt.wantK)
+		}
+		if score < tt.minScore || (tt.maxScore > 0 && score >= tt.maxScore) || math.IsInf(score, 0) || math.IsNaN(score) {
+			t.Errorf("%s: score = %v, want in [%v, %v)", tt.name, score, tt.minScore, tt.maxScore)
+		}
+	}
+
+	if _, score := findChangePoint(repeat(20, 40)); score > 1e-9 {
+		t.Errorf("flat series: score = %v, want 0", score)
+	}
+}
+
+func TestMeasureShift(t *testing.T) {
+	tests := []struct {
+		name          string
+		values        []float64
+		wantOK        bool
+		wantK         int
+		before, after float64
+	}{
+		{name: "flat", values: repeat(20, 60)},
+		{name: "jittered", values: stepSeries(60, 60, 20, 20)},
+		{name: "too few samples", values: stepSeries(2*shiftMinSegment-1, shiftMinSegment, 20, 60)},
+		{name: "tripled", values: stepSeries(60, 25, 20, 60), wantOK: true, wantK: 25, before: 20, after: 60},
+		{name: "divided by four", values: stepSeries(60, 30, 80, 20), wantOK: true, wantK: 30, before: 80, after: 20},
+		{name: "below the minimum ratio", values: stepSeries(60, 30, 20, 26)},
+		{name: "below the minimum delta", values: stepSeries(60, 30, 2, 5)},
+	}
+
+	for _, tt := range tests {
+		k, before, after, ok := measureShift(tt.values)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if ok && (k != tt.wantK || math.Abs(before-tt.before) > 0.5 || math.Abs(after-tt.after) > 0.5) {
+			t.Errorf("%s: %d, %.1f → %.1f ms, want %d, %.1f → %.1f ms", tt.name, k, before, after, tt.wantK, tt.before, tt.after)
+		}
+	}
+}
+
+func TestSameShiftLevel(t *testing.T) {
+	tests := []struct {
+		after, lastAfter float64
+		want             bool
+	}{
+		{60, 60, true},
+		{60, 50, true},    // ×1.2
+		{45, 50, true},    // ×0.9
+		{62.5, 50, false}, // ×1.25
+		{40, 50, false},   // ×0.8
+		{150, 50, false},
+		{60, 0, false}, // No shift reported yet
+	}
+
+	for _, tt := range tests {
+		if got := sameShiftLevel(tt.after, tt.lastAfter); got != tt.want {
+			t.Errorf("sameShiftLevel(%v, %v) = %v, want %v", tt.after, tt.lastAfter, got, tt.want)
+		}
+	}
+}
\ No newline at end of file
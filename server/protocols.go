@@ -0,0 +1,188 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	protocolRecentWindow = time.Hour // Recent period compared with the rest of the report
+	protocolMinSamples   = 5         // Samples required on each side of the comparison
+	reuseDropRatio       = 0.5       // Reuse ratio falling below this share of its earlier level is reported
+	reuseMinRatio        = 50.0      // Earlier reuse ratio, in percent, below which a drop is not reported
+)
+
+// protocolRank orders the HTTP versions, as reported by the probe ("HTTP/1.1",
+// "HTTP/2.0") or as ALPN identifiers ("h2", "h3"). Unknown versions rank 0.
+func protocolRank(protocol string) float64 {
+	p := strings.ToLower(protocol)
+	if strings.HasPrefix(p, "h") && !strings.HasPrefix(p, "http/") {
+		p = strings.TrimPrefix(p, "h")
+	}
+	rank, err := strconv.ParseFloat(strings.TrimPrefix(p, "http/"), 64)
+	if err != nil {
+		return 0
+	}
+	return rank
+}
+
+// protocolAccumulator adds up samples of a protocol or connection kind.
+type protocolAccumulator struct {
+	samples, reused, successes int
+	latencySum                 float64
+}
+
+// add counts a group of samples.
+func (a *protocolAccumulator) add(samples, reused, successes int, latencySum float64) {
+	a.samples += samples
+	a.reused += reused
+	a.successes += successes
+	a.latencySum += latencySum
+}
+
+// avgLatency returns the mean latency of the successful samples.
+func (a *protocolAccumulator) avgLatency() float64 {
+	if a.successes == 0 {
+		return 0
+	}
+	return a.latencySum / float64(a.successes)
+}
+
+// reuseRatio returns the percent of the samples on a reused connection.
+func (a *protocolAccumulator) reuseRatio() float64 {
+	if a.samples == 0 {
+		return 0
+	}
+	return 100 * float64(a.reused) / float64(a.samples)
+}
+
+// dominantProtocol returns the most used protocol of a breakdown.
+func dominantProtocol(counts map[string]int) string {
+	best := ""
+	for protocol, n := range counts {
+		if best == "" || n > counts[best] || (n == counts[best] && protocol < best) {
+			best = protocol
+		}
+	}
+	return best
+}
+
+// getProtocolReport breaks down the HTTP samples of a client received since a
+// given time by protocol and by fresh or reused connection, maintenance
+// excluded. The last protocolRecentWindow is compared with the earlier samples
+// to spot a load balancer refusing keep-alive or downgrading the protocol.
+func (s *Server) getProtocolReport(tenantID, clientID string, since time.Time) (ProtocolReport, error) {
+	report := ProtocolReport{ClientID: clientID, Since: since, Protocols: []ProtocolStats{}}
+	recentSince := time.Now().Add(-protocolRecentWindow)
+	rows, err := s.db.Query(`
+		SELECT timestamp >= ?, protocol, connection_reused, COUNT(*),
+		       COALESCE(SUM(success), 0), COALESCE(SUM(CASE WHEN success = 1 THEN latency ELSE 0 END), 0)
+		FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND timestamp > ? AND maintenance = 0 AND protocol != ''
+		GROUP BY 1, protocol, connection_reused`,
+		recentSince, tenantID, clientID, since)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	var total, fresh, reused, earlier, recent protocolAccumulator
+	byProtocol := make(map[string]*protocolAccumulator)
+	earlierProtocols := make(map[string]int)
+	recentProtocols := make(map[string]int)
+	for rows.Next() {
+		var isRecent, isReused bool
+		var protocol string
+		var samples, successes int
+		var latencySum float64
+		if err := rows.Scan(&isRecent, &protocol, &isReused, &samples, &successes, &latencySum); err != nil {
+			log.Printf("Erreur de scan des protocoles du client %s: %v", clientID, err)
+			continue
+		}
+		reusedSamples := 0
+		if isReused {
+			reusedSamples = samples
+			reused.add(samples, reusedSamples, successes, latencySum)
+		} else {
+			fresh.add(samples, reusedSamples, successes, latencySum)
+		}
+		total.add(samples, reusedSamples, successes, latencySum)
+		if byProtocol[protocol] == nil {
+			byProtocol[protocol] = &protocolAccumulator{}
+		}
+		byProtocol[protocol].add(samples, reusedSamples, successes, latencySum)
+		if isRecent {
+			recent.add(samples, reusedSamples, successes, latencySum)
+			recentProtocols[protocol] += samples
+		} else {
+			earlier.add(samples, reusedSamples, successes, latencySum)
+			earlierProtocols[protocol] += samples
+		}
+	}
+
+	report.Samples = total.samples
+	report.ReuseRatio = total.reuseRatio()
+	report.FreshLatencyMs = fresh.avgLatency()
+	report.ReusedLatencyMs = reused.avgLatency()
+	report.RecentReuseRatio = recent.reuseRatio()
+	report.RecentProtocol = dominantProtocol(recentProtocols)
+	for protocol, a := range byProtocol {
+		report.Protocols = append(report.Protocols, ProtocolStats{
+			Protocol:     protocol,
+			Samples:      a.samples,
+			Share:        100 * float64(a.samples) / float64(total.samples),
+			AvgLatencyMs: a.avgLatency(),
+		})
+	}
+	sort.Slice(report.Protocols, func(i, j int) bool {
+		if report.Protocols[i].Samples != report.Protocols[j].Samples {
+			return report.Protocols[i].Samples > report.Protocols[j].Samples
+		}
+		return report.Protocols[i].Protocol < report.Protocols[j].Protocol
+	})
+
+	if earlier.samples >= protocolMinSamples && recent.samples >= protocolMinSamples {
+		before, after := earlier.reuseRatio(), recent.reuseRatio()
+		if before >= reuseMinRatio && after <= reuseDropRatio*before {
+			report.Warnings = append(report.Warnings,
+				fmt.Sprintf("Réutilisation des connexions en baisse sur la dernière heure : %.0f %% → %.0f %%", before, after))
+		}
+		previous := dominantProtocol(earlierProtocols)
+		if rank := protocolRank(report.RecentProtocol); rank > 0 && rank < protocolRank(previous) {
+			report.Warnings = append(report.Warnings,
+				fmt.Sprintf("Protocole rétrogradé sur la dernière heure : %s → %s", previous, report.RecentProtocol))
+		}
+	}
+	return report, nil
+}
+
+// HandleProtocols returns the protocol and connection reuse breakdown of a
+// client (GET ?client_id=&hours=, 24 hours by default).
+func (s *Server) HandleProtocols(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	clientID := query.Get("client_id")
+	if clientID == "" {
+		http.Error(w, "client_id requis", http.StatusBadRequest)
+		return
+	}
+	hours := 24
+	if h, err := strconv.Atoi(query.Get("hours")); err == nil && h > 0 {
+		hours = h
+	}
+	report, err := s.getProtocolReport(s.requestTenant(r), clientID, time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		log.Printf("Erreur de calcul des protocoles du client %s: %v", clientID, err)
+		http.Error(w, "Erreur de calcul des protocoles", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
\ No newline at end of file
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	ipChangeWindow      = 30 * time.Minute // Samples compared on each side of an address change
+	ipDegradedRatio     = 1.5              // Latency increase after a change reported as a degradation
+	ipDegradedErrorRate = 10.0             // Error rate increase, in points, reported as a degradation
+	ipRotationWindow    = 24 * time.Hour   // Addresses seen this recently take turns without being a change
+	maxListedIPChanges  = 100
+)
+
+// validateExpectedIPs checks the expected addresses of a check and normalizes
+// the CIDR ranges.
+func validateExpectedIPs(expected []string) error {
+	for i, entry := range expected {
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return errors.New("expected_ips: adresse ou plage invalide: " + entry)
+		}
+		expected[i] = network.String()
+	}
+	return nil
+}
+
+// ipExpected reports whether a remote address is one of the expected
+// addresses or ranges. Any address is expected when none are defined.
+func ipExpected(expected []string, remoteIP string) bool {
+	if len(expected) == 0 {
+		return true
+	}
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range expected {
+		if e := net.ParseIP(entry); e != nil {
+			if e.Equal(ip) {
+				return true
+			}
+		} else if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe sets the message of an address change, e.g. "https://example.com
+// : 192.0.2.1 → 192.0.2.7 le 18/10 14:05 (latence 20 → 45 ms, erreurs 0 → 12 %)".
+func (c *IPChange) describe() {
+	c.Message = fmt.Sprintf("%s : %s → %s le %s", c.TargetURL, c.PreviousIP, c.NewIP, c.ChangedAt.Local().Format("02/01 15:04"))
+	if c.AfterLatencyMs > 0 || c.AfterErrorRate > 0 {
+		c.Message += fmt.Sprintf(" (latence %.0f → %.0f ms, erreurs %.0f → %.0f %%)",
+			c.BeforeLatencyMs, c.AfterLatencyMs, c.BeforeErrorRate, c.AfterErrorRate)
+	}
+}
+
+// trackRemoteIP records the address the target of a sample resolved to. An
+// address other than the last one seen and not seen within ipRotationWindow
+// is kept as a change, so that round-robin and CDN targets alternating
+// between their addresses do not record one on every sample. A change is
+// flagged as unexpected when the address was never seen before or is outside
+// the expected addresses of the check.
+func (s *Server) trackRemoteIP(tenantID string, def CheckDefinition, data MonitoringData) error {
+	remoteIP := data.NetworkInfo.RemoteIP
+	if remoteIP == "" || data.TargetURL == "" {
+		return nil
+	}
+
+	now := time.Now()
+	var current string
+	err := s.db.QueryRow(`
+		SELECT remote_ip FROM remote_ips
+		WHERE tenant_id = ? AND client_id = ? AND target_url = ?
+		ORDER BY last_seen DESC LIMIT 1`,
+		tenantID, data.ClientID, data.TargetURL).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	var lastSeen time.Time
+	err = s.db.QueryRow(`
+		SELECT last_seen FROM remote_ips
+		WHERE tenant_id = ? AND client_id = ? AND target_url = ? AND remote_ip = ?`,
+		tenantID, data.ClientID, data.TargetURL, remoteIP).Scan(&lastSeen)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	known := err == nil
+
+	if _, err := s.db.Exec(`
+		INSERT INTO remote_ips (tenant_id, client_id, target_url, remote_ip, first_seen, last_seen, samples)
+		VALUES (?, ?, ?, ?, ?, ?, 1)
+		ON CONFLICT(tenant_id, client_id, target_url, remote_ip) DO UPDATE SET
+			last_seen = excluded.last_seen,
+			samples = samples + 1`,
+		tenantID, data.ClientID, data.TargetURL, remoteIP, now, now); err != nil {
+		return err
+	}
+
+	if current == "" || current == remoteIP || (known && now.Sub(lastSeen) < ipRotationWindow) {
+		return nil
+	}
+	unexpected := !known || !ipExpected(def.ExpectedIPs, remoteIP)
+	if unexpected {
+		log.Printf("🌐 Adresse inattendue pour %s (%s): %s → %s", data.TargetURL, data.ClientID, current, remoteIP)
+	} else {
+		log.Printf("🌐 Adresse de %s (%s): %s → %s", data.TargetURL, data.ClientID, current, remoteIP)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO ip_changes (tenant_id, client_id, target_url, previous_ip, new_ip, changed_at, unexpected)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		tenantID, data.ClientID, data.TargetURL, current, remoteIP, now, unexpected)
+	return err
+}
+
+// listRemoteIPs returns the addresses the targets of a tenant resolved to,
+// for one client or all (""), most recently seen first.
+func (s *Server) listRemoteIPs(tenantID, clientID string) ([]RemoteIP, error) {
+	defs, err := s.listCheckDefinitions(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string][]string)
+	for _, def := range defs {
+		expected[def.ClientID] = def.ExpectedIPs
+	}
+
+	rows, err := s.db.Query(`
+		SELECT tenant_id, client_id, target_url, remote_ip, first_seen, last_seen, samples
+		FROM remote_ips
+		WHERE tenant_id = ? AND (? = '' OR client_id = ?)
+		ORDER BY client_id, target_url, last_seen DESC`,
+		tenantID, clientID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ips := []RemoteIP{}
+	for rows.Next() {
+		var ip RemoteIP
+		if err := rows.Scan(&ip.TenantID, &ip.ClientID, &ip.TargetURL, &ip.RemoteIP, &ip.FirstSeen, &ip.LastSeen, &ip.Samples); err != nil {
+			log.Printf("Erreur de scan des adresses des cibles: %v", err)
+			continue
+		}
+		// The first row of each target is its current address
+		n := len(ips)
+		ip.Current = n == 0 || ips[n-1].ClientID != ip.ClientID || ips[n-1].TargetURL != ip.TargetURL
+		ip.Expected = ipExpected(expected[ip.ClientID], ip.RemoteIP)
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// windowStats returns the number of samples of a client's target between two
+// times, with their mean successful latency and error rate, maintenance
+// excluded.
+func (s *Server) windowStats(tenantID, clientID, targetURL string, from, to time.Time) (int, float64, float64, error) {
+	var samples, failures int
+	var latency sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN success = 0 THEN 1 ELSE 0 END), 0), AVG(CASE WHEN success = 1 THEN latency END)
+		FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND target_url = ? AND maintenance = 0
+		  AND timestamp >= ? AND timestamp < ?`,
+		tenantID, clientID, targetURL, from, to).Scan(&samples, &failures, &latency)
+	if err != nil || samples == 0 {
+		return 0, 0, 0, err
+	}
+	return samples, latency.Float64, 100 * float64(failures) / float64(samples), nil
+}
+
+// listIPChanges returns the last maxListedIPChanges address changes of a
+// tenant since a given time, most recent first, for one client or all ("").
+func (s *Server) listIPChanges(tenantID, clientID string, since time.Time) ([]IPChange, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, client_id, target_url, previous_ip, new_ip, changed_at, unexpected
+		FROM ip_changes
+		WHERE tenant_id = ? AND (? = '' OR client_id = ?) AND changed_at > ?
+		ORDER BY changed_at DESC
+		LIMIT ?`,
+		tenantID, clientID, clientID, since, maxListedIPChanges)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []IPChange{}
+	for rows.Next() {
+		var c IPChange
+		if err := rows.Scan(&c.ID, &c.TenantID, &c.ClientID, &c.TargetURL, &c.PreviousIP, &c.NewIP, &c.ChangedAt, &c.Unexpected); err != nil {
+			log.Printf("Erreur de scan des changements d'adresse: %v", err)
+			continue
+		}
+		c.describe()
+		changes = append(changes, c)
+	}
+	rows.Close()
+	return changes, nil
+}
+
+// compareIPChanges compares each address change with the latency and errors
+// of its target over ipChangeWindow on either side.
+func (s *Server) compareIPChanges(tenantID string, changes []IPChange) error {
+	var err error
+	for i := range changes {
+		c := &changes[i]
+		if _, c.BeforeLatencyMs, c.BeforeErrorRate, err = s.windowStats(tenantID, c.ClientID, c.TargetURL, c.ChangedAt.Add(-ipChangeWindow), c.ChangedAt); err != nil {
+			return err
+		}
+		var after int
+		if after, c.AfterLatencyMs, c.AfterErrorRate, err = s.windowStats(tenantID, c.ClientID, c.TargetURL, c.ChangedAt, c.ChangedAt.Add(ipChangeWindow)); err != nil {
+			return err
+		}
+		c.Degraded = after > 0 &&
+			((c.BeforeLatencyMs > 0 && c.AfterLatencyMs >= ipDegradedRatio*c.BeforeLatencyMs) ||
+				c.AfterErrorRate-c.BeforeErrorRate >= ipDegradedErrorRate)
+		c.describe()
+	}
+	return nil
+}
+
+// evaluateRemoteIPChanged finds the targets whose last address change within
+// "hold_hours" (24) was unexpected, or any change with "include_expected",
+// and the targets currently resolving outside the expected addresses of
+// their check.
+func (s *Server) evaluateRemoteIPChanged(rule AlertRule) ([]alertFinding, error) {
+	hold := time.Duration(rule.param("hold_hours", 24) * float64(time.Hour))
+	includeExpected := rule.param("include_expected", 0) != 0
+	changes, err := s.listIPChanges(rule.TenantID, rule.ClientID, time.Now().Add(-hold))
+	if err != nil {
+		return nil, err
+	}
+	ips, err := s.listRemoteIPs(rule.TenantID, rule.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []alertFinding
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		if ip.Current && !ip.Expected {
+			seen[ip.ClientID+"\x00"+ip.TargetURL] = true
+			findings = append(findings, alertFinding{ClientID: ip.ClientID, Target: ip.TargetURL,
+				Message: fmt.Sprintf("%s est résolue vers %s, hors des adresses attendues", ip.TargetURL, ip.RemoteIP)})
+		}
+	}
+	for _, c := range changes {
+		key := c.ClientID + "\x00" + c.TargetURL
+		if seen[key] {
+			continue // Only the last change of a target counts
+		}
+		seen[key] = true
+		if c.Unexpected || includeExpected {
+			findings = append(findings, alertFinding{ClientID: c.ClientID, Target: c.TargetURL, Message: "Changement d'adresse de " + c.Message})
+		}
+	}
+	return findings, nil
+}
+
+// HandleRemoteIPs returns the addresses the tenant's targets resolved to and
+// their last changes (GET ?client_id=&days=, 7 days of changes by default).
+func (s *Server) HandleRemoteIPs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	tenantID, clientID := s.requestTenant(r), query.Get("client_id")
+	days := 7
+	if d, err := strconv.Atoi(query.Get("days")); err == nil && d > 0 {
+		days = d
+	}
+
+	ips, err := s.listRemoteIPs(tenantID, clientID)
+	if err != nil {
+		log.Printf("Erreur de récupération des adresses des cibles: %v", err)
+		http.Error(w, "Erreur de récupération des adresses", http.StatusInternalServerError)
+		return
+	}
+	changes, err := s.listIPChanges(tenantID, clientID, time.Now().AddDate(0, 0, -days))
+	if err == nil {
+		err = s.compareIPChanges(tenantID, changes)
+	}
+	if err != nil {
+		log.Printf("Erreur de récupération des changements d'adresse: %v", err)
+		http.Error(w, "Erreur de récupération des adresses", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		IPs     []RemoteIP `json:"ips"`
+		Changes []IPChange `json:"changes"`
+	}{ips, changes})
+}
\ No newline at end of file
@@ -0,0 +1,425 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// uptimeTally accumulates the time a client spent up, down or in maintenance
+// within the bounds of a report.
+type uptimeTally struct {
+	from, to              time.Time
+	windows               []MaintenanceWindow
+	up, down, maintenance time.Duration
+	incidents             int
+	inIncident            bool
+}
+
+// add counts [start, end) as up or down, without the maintenance windows. A
+// down period following an up one starts a new incident.
+func (t *uptimeTally) add(start, end time.Time, up bool) {
+	if start.Before(t.from) {
+		start = t.from
+	}
+	if end.After(t.to) {
+		end = t.to
+	}
+	if !end.After(start) {
+		return
+	}
+	excluded := maintenanceOverlap(start, end, t.windows)
+	t.maintenance += excluded
+	d := end.Sub(start) - excluded
+	if d <= 0 {
+		return
+	}
+	if up {
+		t.up += d
+		t.inIncident = false
+		return
+	}
+	t.down += d
+	if !t.inIncident {
+		t.incidents++
+		t.inIncident = true
+	}
+}
+
+type uptimeSample struct {
+	at      time.Time
+	success bool
+}
+
+// clientUptime computes the availability of a client over [from, to). Each
+// sample vouches for the state of the target until the next one, or for one
+// interval when the next comes too late; the rest is a gap, counted as down
+// under the down gap policy and left out otherwise.
+func (s *Server) clientUptime(tenantID, clientID string, interval int, from, to time.Time, gapPolicy string, windows []MaintenanceWindow) (ClientUptime, error) {
+	result := ClientUptime{ClientID: clientID}
+	if now := time.Now(); now.Before(to) {
+		to = now
+	}
+
+	var samples []uptimeSample
+	var previous uptimeSample
+	err := s.db.QueryRow(`
+		SELECT timestamp, success FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND timestamp <= ?
+		ORDER BY timestamp DESC LIMIT 1`,
+		tenantID, clientID, from).Scan(&previous.at, &previous.success)
+	if err == nil {
+		samples = append(samples, previous)
+	} else if err != sql.ErrNoRows {
+		return result, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT timestamp, success FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND timestamp > ? AND timestamp < ?
+		ORDER BY timestamp ASC`,
+		tenantID, clientID, from, to)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var smp uptimeSample
+		if err := rows.Scan(&smp.at, &smp.success); err != nil {
+			log.Printf("Erreur de scan de l'historique pour la disponibilité du client %s: %v", clientID, err)
+			continue
+		}
+		samples = append(samples, smp)
+		result.Samples++
+	}
+
+	tally := uptimeTally{from: from, to: to, windows: windows}
+	vouched := time.Duration(intervalSecondsOrDefault(interval)) * time.Second
+	for i, smp := range samples {
+		next := to
+		if i+1 < len(samples) {
+			next = samples[i+1].at
+		}
+		if float64(next.Sub(smp.at)) <= lateMultiple*float64(vouched) {
+			tally.add(smp.at, next, smp.success)
+			continue
+		}
+		tally.add(smp.at, smp.at.Add(vouched), smp.success)
+		if gapPolicy == GapPolicyDown {
+			tally.add(smp.at.Add(vouched), next, false)
+		}
+	}
+
+	monitored := tally.up + tally.down
+	result.MonitoredSeconds = monitored.Seconds()
+	result.DowntimeMinutes = tally.down.Minutes()
+	result.MaintenanceMinutes = tally.maintenance.Minutes()
+	result.Incidents = tally.incidents
+	if monitored > 0 {
+		result.Uptime = float64(tally.up) / float64(monitored) * 100.0
+	}
+	if tally.incidents > 0 {
+		result.MTTRSeconds = tally.down.Seconds() / float64(tally.incidents)
+		result.MTBFSeconds = tally.up.Seconds() / float64(tally.incidents)
+	}
+	return result, nil
+}
+
+// intervalSecondsOrDefault guards against intervals that were never set.
+func intervalSecondsOrDefault(interval int) int {
+	if interval <= 0 {
+		return defaultReportInterval
+	}
+	return interval
+}
+
+// getUptimeReport computes the availability of every client of a tenant over
+// [from, to), excluding maintenance windows. With a positive SLA target,
+// clients are flagged according to whether they met it. A report starting
+// before the retention of the tenant is flagged with the start of the history
+// it was computed from.
+func (s *Server) getUptimeReport(tenantID, period string, from, to time.Time, slaTarget float64) (UptimeReport, error) {
+	report := UptimeReport{
+		TenantID:  tenantID,
+		Period:    period,
+		From:      from,
+		To:        to,
+		GapPolicy: GapPolicyIgnore,
+		SLATarget: slaTarget,
+		Clients:   []ClientUptime{},
+	}
+	if t, err := s.getTenant(tenantID); err == nil {
+		report.GapPolicy = t.GapPolicy
+	}
+	if cutoff := time.Now().AddDate(0, 0, -s.retentionDays(tenantID)); from.Before(cutoff) {
+		report.HistoryFrom = &cutoff
+	}
+
+	windows, err := s.maintenanceOccurrences(tenantID, from, to, 0)
+	if err != nil {
+		return report, err
+	}
+	tags := make(map[string][]string)
+	if defs, err := s.listCheckDefinitions(tenantID); err == nil {
+		for _, def := range defs {
+			tags[def.ClientID] = def.Tags
+		}
+	}
+
+	rows, err := s.db.Query(`SELECT id, target_url FROM clients WHERE tenant_id = ? ORDER BY id`, tenantID)
+	if err != nil {
+		return report, err
+	}
+	type clientRow struct{ id, targetURL string }
+	var clients []clientRow
+	for rows.Next() {
+		var c clientRow
+		if err := rows.Scan(&c.id, &c.targetURL); err != nil {
+			log.Printf("Erreur de scan de la ligne client: %v", err)
+			continue
+		}
+		clients = append(clients, c)
+	}
+	rows.Close()
+
+	for _, c := range clients {
+		interval, err := s.clientInterval(tenantID, c.id)
+		if err != nil {
+			log.Printf("Erreur de récupération de l'intervalle du client %s: %v", c.id, err)
+			continue
+		}
+		var clientWindows []MaintenanceWindow
+		for _, mw := range windows {
+			if mw.appliesTo(c.id, c.targetURL, tags[c.id]) {
+				clientWindows = append(clientWindows, mw)
+			}
+		}
+		uptime, err := s.clientUptime(tenantID, c.id, interval, from, to, report.GapPolicy, clientWindows)
+		if err != nil {
+			log.Printf("Erreur de calcul de la disponibilité du client %s: %v", c.id, err)
+			continue
+		}
+		uptime.TargetURL = c.targetURL
+		uptime.SLAMet = slaTarget <= 0 || uptime.MonitoredSeconds == 0 || uptime.Uptime >= slaTarget
+		report.Clients = append(report.Clients, uptime)
+	}
+	return report, nil
+}
+
+// reportPeriod reads the bounds of a report from the query: period=day, week
+// or month around date (YYYY-MM-DD or YYYY-MM, today by default), or
+// period=custom between from and to (YYYY-MM-DD, to included). Weeks start on
+// Monday and bounds are in local time.
+func reportPeriod(query url.Values, now time.Time) (string, time.Time, time.Time, error) {
+	period := query.Get("period")
+	if period == "" {
+		period = "month"
+	}
+
+	if period == "custom" {
+		from, err := time.ParseInLocation("2006-01-02", query.Get("from"), time.Local)
+		if err != nil {
+			return period, time.Time{}, time.Time{}, errors.New("from invalide (AAAA-MM-JJ)")
+		}
+		to, err := time.ParseInLocation("2006-01-02", query.Get("to"), time.Local)
+		if err != nil || to.Before(from) {
+			return period, time.Time{}, time.Time{}, errors.New("to invalide (AAAA-MM-JJ, après from)")
+		}
+		return period, from, to.AddDate(0, 0, 1), nil
+	}
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if v := query.Get("date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			if t, err = time.ParseInLocation("2006-01", v, time.Local); err != nil {
+				return period, time.Time{}, time.Time{}, errors.New("date invalide (AAAA-MM-JJ ou AAAA-MM)")
+			}
+		}
+		day = t
+	}
+
+	switch period {
+	case "day":
+		return period, day, day.AddDate(0, 0, 1), nil
+	case "week":
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return period, start, start.AddDate(0, 0, 7), nil
+	case "month":
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+		return period, start, start.AddDate(0, 1, 0), nil
+	}
+	return period, time.Time{}, time.Time{}, errors.New("period invalide (day, week, month ou custom)")
+}
+
+// parseSLATarget reads the optional SLA target percentage of a report.
+func parseSLATarget(query url.Values) (float64, error) {
+	v := query.Get("sla")
+	if v == "" {
+		return 0, nil
+	}
+	target, err := strconv.ParseFloat(v, 64)
+	if err != nil || target < 0 || target > 100 {
+		return 0, errors.New("sla invalide (pourcentage)")
+	}
+	return target, nil
+}
+
+// formatSeconds formats a duration in seconds for the report and incident
+// pages, to the millisecond under a minute and to the second above.
+func formatSeconds(sec float64) string {
+	d := time.Duration(sec * float64(time.Second))
+	if d < time.Minute {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// writeUptimeCSV writes a report as CSV, one line per client. history_from is
+// set when the report starts before the history still kept.
+func writeUptimeCSV(w http.ResponseWriter, report UptimeReport) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="uptime-%s-%s.csv"`,
+		report.From.Format("20060102"), report.To.AddDate(0, 0, -1).Format("20060102")))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"client_id", "target_url", "from", "to", "uptime", "downtime_minutes", "incidents",
+		"mttr_seconds", "mtbf_seconds", "maintenance_minutes", "samples", "sla_met", "history_from"})
+	historyFrom := ""
+	if report.HistoryFrom != nil {
+		historyFrom = report.HistoryFrom.Format(time.RFC3339)
+	}
+	for _, c := range report.Clients {
+		cw.Write([]string{
+			c.ClientID,
+			c.TargetURL,
+			report.From.Format(time.RFC3339),
+			report.To.Format(time.RFC3339),
+			strconv.FormatFloat(c.Uptime, 'f', 3, 64),
+			strconv.FormatFloat(c.DowntimeMinutes, 'f', 1, 64),
+			strconv.Itoa(c.Incidents),
+			strconv.FormatFloat(c.MTTRSeconds, 'f', 3, 64),
+			strconv.FormatFloat(c.MTBFSeconds, 'f', 3, 64),
+			strconv.FormatFloat(c.MaintenanceMinutes, 'f', 1, 64),
+			strconv.Itoa(c.Samples),
+			strconv.FormatBool(c.SLAMet),
+			historyFrom,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("Erreur d'écriture du rapport CSV: %v", err)
+	}
+}
+
+// HandleUptimeReport returns the availability of the tenant's clients over a
+// calendar period (GET ?period=&date=&from=&to=&sla=&format=json|csv).
+func (s *Server) HandleUptimeReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	period, from, to, err := reportPeriod(query, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slaTarget, err := parseSLATarget(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.getUptimeReport(s.requestTenant(r), period, from, to, slaTarget)
+	if err != nil {
+		log.Printf("Erreur de calcul du rapport de disponibilité: %v", err)
+		http.Error(w, "Erreur de calcul du rapport", http.StatusInternalServerError)
+		return
+	}
+
+	switch query.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, report)
+	case "csv":
+		writeUptimeCSV(w, report)
+	default:
+		http.Error(w, "format invalide (json ou csv)", http.StatusBadRequest)
+	}
+}
+
+// HandleReportsPage renders the monthly availability report of the tenant's
+// clients (GET ?date=YYYY-MM&sla=).
+func (s *Server) HandleReportsPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Set("period", "month")
+	_, from, to, err := reportPeriod(query, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slaTarget, err := parseSLATarget(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.Get("sla") == "" {
+		slaTarget = 99.9
+	}
+
+	tenantID := s.requestTenant(r)
+	report, err := s.getUptimeReport(tenantID, "month", from, to, slaTarget)
+	if err != nil {
+		log.Printf("Erreur de calcul du rapport de disponibilité: %v", err)
+		http.Error(w, "Erreur de calcul du rapport", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.New("reports.html").
+		Funcs(template.FuncMap{
+			"formatSeconds": formatSeconds,
+		}).
+		ParseFiles("templates/reports.html")
+	if err != nil {
+		log.Printf("Erreur de chargement du template des rapports: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := struct {
+		CurrentUser   *User
+		CurrentTenant string
+		Report        UptimeReport
+		Month         string
+		PrevMonth     string
+		NextMonth     string
+		SLA           string
+	}{
+		CurrentUser:   userFromContext(r.Context()),
+		CurrentTenant: tenantID,
+		Report:        report,
+		Month:         from.Format("2006-01"),
+		PrevMonth:     from.AddDate(0, -1, 0).Format("2006-01"),
+		NextMonth:     to.Format("2006-01"),
+		SLA:           strconv.FormatFloat(slaTarget, 'f', -1, 64),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := tmpl.Execute(w, pageData); err != nil {
+		log.Printf("Erreur lors de l'exécution du template des rapports: %v", err)
+	}
+}
\ No newline at end of file
@@ -3,12 +3,24 @@ package server
 import (
 	"database/sql"
 	"log"
+	"sync"
 	"time"
 )
 
 // Server structure holds the database connection and methods.
 type Server struct {
-	db *sql.DB
+	db                *sql.DB
+	hub               *wsHub
+	adminToken        string
+	requireClientCert bool
+	incidentMu        sync.Mutex // Serializes incident updates from concurrent samples
+	baselineMu        sync.Mutex
+	baselines         map[string]*LatencyBaseline // Latency baselines by tenant and client
+	shiftMu           sync.Mutex
+	shiftChecks       map[string]time.Time // Last phase shift detection by tenant and client
+	siteMu            sync.Mutex
+	siteSubnetCache   map[string][]siteSubnet // Parsed site subnets by tenant
+	siteGeneration    uint64                  // Incremented on every site change
 }
 
 // NewServer creates a new Server instance, initializes the database, and starts cleanup.
@@ -19,15 +31,34 @@ func NewServer(dbPath string) (*Server, error) {
 	}
 
 	s := &Server{
-		db: db,
+		db:              db,
+		hub:             newWSHub(),
+		baselines:       make(map[string]*LatencyBaseline),
+		shiftChecks:     make(map[string]time.Time),
+		siteSubnetCache: make(map[string][]siteSubnet),
 	}
 
 	// Start the cleanup routine in a goroutine
 	go s.cleanupRoutine()
 
+	// Evaluate the alert rules periodically
+	go s.alertRoutine()
+
 	return s, nil
 }
 
+// SetAdminToken sets the secret expected by the admin API. An empty token
+// disables the admin API.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetRequireClientCert makes /data accept only probes presenting a verified
+// client certificate, refusing bearer tokens alone.
+func (s *Server) SetRequireClientCert(required bool) {
+	s.requireClientCert = required
+}
+
 // Close closes the database connection.
 func (s *Server) Close() error {
 	if s.db != nil {
@@ -42,15 +73,18 @@ func (s *Server) cleanupRoutine() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		// Delete data older than 7 days
-		_, err := s.db.Exec(`
-			DELETE FROM client_history
-			WHERE timestamp < datetime('now', '-7 days')`)
+		// Delete data older than each tenant's retention
+		err := s.purgeExpiredHistory()
 
 		if err != nil {
 			log.Printf("Erreur nettoyage base: %v", err)
 		} else {
 			log.Println("Nettoyage automatique des anciennes données effectué")
 		}
+
+		// Delete expired sessions
+		if _, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at < ?`, time.Now()); err != nil {
+			log.Printf("Erreur nettoyage des sessions: %v", err)
+		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSilenceDuration bounds how long alerts can be silenced at once.
+const maxSilenceDuration = 30 * 24 * time.Hour
+
+// matches reports whether the silence covers the alerts of a rule for a client.
+func (sl Silence) matches(clientID string, ruleID int64) bool {
+	return (sl.ClientID == "" || sl.ClientID == clientID) && (sl.RuleID == 0 || sl.RuleID == ruleID)
+}
+
+// listSilences returns the silences of a tenant still active at the given time.
+func (s *Server) listSilences(tenantID string, at time.Time) ([]Silence, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, client_id, rule_id, COALESCE(reason, ''), COALESCE(created_by, ''), created_at, expires_at
+		FROM silences
+		WHERE tenant_id = ? AND expires_at > ?
+		ORDER BY expires_at`,
+		tenantID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	silences := []Silence{}
+	for rows.Next() {
+		var sl Silence
+		if err := rows.Scan(&sl.ID, &sl.TenantID, &sl.ClientID, &sl.RuleID, &sl.Reason, &sl.CreatedBy, &sl.CreatedAt, &sl.ExpiresAt); err != nil {
+			log.Printf("Erreur de scan des silences: %v", err)
+			continue
+		}
+		silences = append(silences, sl)
+	}
+	return silences, nil
+}
+
+// createSilence records a silence.
+func (s *Server) createSilence(sl *Silence) error {
+	sl.CreatedAt = time.Now()
+	if !sl.ExpiresAt.After(sl.CreatedAt) {
+		return errors.New("expires_at doit être dans le futur")
+	}
+	if sl.ExpiresAt.Sub(sl.CreatedAt) > maxSilenceDuration {
+		return errors.New("un silence dure 30 jours au plus")
+	}
+	res, err := s.db.Exec(`
+		INSERT INTO silences (tenant_id, client_id, rule_id, reason, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sl.TenantID, sl.ClientID, sl.RuleID, sl.Reason, sl.CreatedBy, sl.CreatedAt, sl.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	sl.ID, err = res.LastInsertId()
+	return err
+}
+
+// alertSuppression returns a function telling whether the alerts of a rule
+// for a client are suppressed at the given time, because of a silence or a
+// running maintenance window.
+func (s *Server) alertSuppression(tenantID string, at time.Time) (func(clientID string, ruleID int64) bool, error) {
+	silences, err := s.listSilences(tenantID, at)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := s.activeMaintenance(tenantID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string)
+	tags := make(map[string][]string)
+	if len(windows) > 0 {
+		rows, err := s.db.Query(`SELECT id, target_url FROM clients WHERE tenant_id = ?`, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, targetURL string
+			if err := rows.Scan(&id, &targetURL); err == nil {
+				targets[id] = targetURL
+			}
+		}
+		rows.Close()
+		if defs, err := s.listCheckDefinitions(tenantID); err == nil {
+			for _, def := range defs {
+				tags[def.ClientID] = def.Tags
+			}
+		}
+	}
+
+	return func(clientID string, ruleID int64) bool {
+		for _, sl := range silences {
+			if sl.matches(clientID, ruleID) {
+				return true
+			}
+		}
+		for _, mw := range windows {
+			if mw.appliesTo(clientID, targets[clientID], tags[clientID]) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// HandleSilences lists the active silences of the tenant (GET), creates one
+// (POST, with expires_at or duration_minutes) and expires one early (DELETE
+// ?id=). Changes require the operator role.
+func (s *Server) HandleSilences(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	tenantID := s.requestTenant(r)
+	if r.Method != http.MethodGet && !user.hasRole(RoleOperator) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		silences, err := s.listSilences(tenantID, time.Now())
+		if err != nil {
+			log.Printf("Erreur de récupération des silences: %v", err)
+			http.Error(w, "Erreur de récupération des silences", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, silences)
+
+	case http.MethodPost:
+		var req struct {
+			Silence
+			DurationMinutes int `json:"duration_minutes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		sl := req.Silence
+		if req.DurationMinutes > 0 {
+			sl.ExpiresAt = time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+		}
+		sl.TenantID = tenantID
+		sl.CreatedBy = user.Username
+		if err := s.createSilence(&sl); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "silence_create", strconv.FormatInt(sl.ID, 10),
+			fmt.Sprintf("client=%s rule=%d expires=%s", sl.ClientID, sl.RuleID, sl.ExpiresAt.Format(time.RFC3339)))
+		writeJSON(w, http.StatusCreated, sl)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		res, err := s.db.Exec(`UPDATE silences SET expires_at = ? WHERE id = ? AND tenant_id = ? AND expires_at > ?`, now, id, tenantID, now)
+		if err != nil {
+			log.Printf("Erreur d'expiration du silence %d: %v", id, err)
+			http.Error(w, "Erreur d'expiration du silence", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Silence introuvable ou expiré", http.StatusNotFound)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "silence_expire", strconv.FormatInt(id, 10), "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
\ No newline at end of file
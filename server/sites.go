@@ -0,0 +1,412 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// noSiteName groups the clients without a site in the summaries.
+const noSiteName = ""
+
+var sitePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._-]{0,62}$`)
+
+// validateSite checks a site and normalizes its subnets.
+func validateSite(site *Site) error {
+	if !sitePattern.MatchString(site.Name) {
+		return errors.New("name invalide (lettres, chiffres, espaces, . _ et -)")
+	}
+	if site.Latitude < -90 || site.Latitude > 90 || site.Longitude < -180 || site.Longitude > 180 {
+		return errors.New("latitude ou longitude invalide")
+	}
+	for i, subnet := range site.Subnets {
+		_, network, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return errors.New("sous-réseau invalide: " + subnet)
+		}
+		site.Subnets[i] = network.String()
+	}
+	return nil
+}
+
+// siteSubnet is a parsed subnet of a site.
+type siteSubnet struct {
+	site    string
+	network *net.IPNet
+}
+
+// listSites returns the sites of a tenant.
+func (s *Server) listSites(tenantID string) ([]Site, error) {
+	rows, err := s.db.Query(`
+		SELECT name, tenant_id, region, latitude, longitude, subnets
+		FROM sites
+		WHERE tenant_id = ?
+		ORDER BY name`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sites := []Site{}
+	for rows.Next() {
+		var site Site
+		var subnets sql.NullString
+		if err := rows.Scan(&site.Name, &site.TenantID, &site.Region, &site.Latitude, &site.Longitude, &subnets); err != nil {
+			log.Printf("Erreur de scan des sites: %v", err)
+			continue
+		}
+		if subnets.String != "" {
+			json.Unmarshal([]byte(subnets.String), &site.Subnets)
+		}
+		sites = append(sites, site)
+	}
+	return sites, nil
+}
+
+// saveSite creates or updates a site.
+func (s *Server) saveSite(site *Site) error {
+	if err := validateSite(site); err != nil {
+		return err
+	}
+	subnets, _ := json.Marshal(site.Subnets)
+	_, err := s.db.Exec(`
+		INSERT INTO sites (tenant_id, name, region, latitude, longitude, subnets)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, name) DO UPDATE SET
+			region = excluded.region,
+			latitude = excluded.latitude,
+			longitude = excluded.longitude,
+			subnets = excluded.subnets`,
+		site.TenantID, site.Name, site.Region, site.Latitude, site.Longitude, string(subnets))
+	if err == nil {
+		s.forgetSiteSubnets(site.TenantID)
+	}
+	return err
+}
+
+// siteSubnets returns the parsed subnets of the sites of a tenant, in site
+// order, loaded once and kept until the sites change. A load that raced with
+// a change is returned but not cached.
+func (s *Server) siteSubnets(tenantID string) ([]siteSubnet, error) {
+	s.siteMu.Lock()
+	subnets, ok := s.siteSubnetCache[tenantID]
+	generation := s.siteGeneration
+	s.siteMu.Unlock()
+	if ok {
+		return subnets, nil
+	}
+
+	sites, err := s.listSites(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	subnets = []siteSubnet{}
+	for _, site := range sites {
+		for _, subnet := range site.Subnets {
+			if _, network, err := net.ParseCIDR(subnet); err == nil {
+				subnets = append(subnets, siteSubnet{site.Name, network})
+			}
+		}
+	}
+	s.siteMu.Lock()
+	if s.siteGeneration == generation {
+		s.siteSubnetCache[tenantID] = subnets
+	}
+	s.siteMu.Unlock()
+	return subnets, nil
+}
+
+// forgetSiteSubnets drops the cached subnets of a tenant after its sites
+// changed.
+func (s *Server) forgetSiteSubnets(tenantID string) {
+	s.siteMu.Lock()
+	delete(s.siteSubnetCache, tenantID)
+	s.siteGeneration++
+	s.siteMu.Unlock()
+}
+
+// resolveSite returns the site of a sample: the one declared by the probe,
+// or else the site whose subnets contain the probe's local IP ("" if none).
+func (s *Server) resolveSite(tenantID string, data MonitoringData) string {
+	if data.Site != "" {
+		return data.Site
+	}
+	ip := net.ParseIP(data.NetworkInfo.LocalIP)
+	if ip == nil {
+		return ""
+	}
+	subnets, err := s.siteSubnets(tenantID)
+	if err != nil {
+		log.Printf("Erreur de récupération des sites: %v", err)
+		return ""
+	}
+	for _, subnet := range subnets {
+		if subnet.network.Contains(ip) {
+			return subnet.site
+		}
+	}
+	return ""
+}
+
+// siteStatsAccumulator adds up samples into site statistics.
+type siteStatsAccumulator struct {
+	samples, successes int
+	latencySum         float64
+}
+
+// add counts a group of samples.
+func (a *siteStatsAccumulator) add(samples, successes int, latencySum float64) {
+	a.samples += samples
+	a.successes += successes
+	a.latencySum += latencySum
+}
+
+// stats returns the statistics of the samples counted for a site.
+func (a siteStatsAccumulator) stats(site string) SiteStats {
+	st := SiteStats{Site: site, Samples: a.samples}
+	if a.samples > 0 {
+		st.SuccessRate = float64(a.successes) / float64(a.samples) * 100
+	}
+	if a.successes > 0 {
+		st.AvgLatencyMs = a.latencySum / float64(a.successes)
+	}
+	return st
+}
+
+// getSiteReport aggregates the health and latency of a tenant by site over
+// the samples received since a given time, overall and by target, so that
+// sites probing the same target can be compared. Samples count for the
+// current site of their client. Latencies average the successful samples;
+// maintenance is excluded.
+func (s *Server) getSiteReport(tenantID string, since time.Time) (SiteReport, error) {
+	report := SiteReport{Since: since, Sites: []SiteSummary{}, Targets: []TargetSiteComparison{}}
+
+	sites, err := s.listSites(tenantID)
+	if err != nil {
+		return report, err
+	}
+	clients, err := s.getClientStatuses(tenantID)
+	if err != nil {
+		return report, err
+	}
+
+	summaries := make(map[string]*SiteSummary)
+	summary := func(name string) *SiteSummary {
+		if summaries[name] == nil {
+			summaries[name] = &SiteSummary{Site: Site{Name: name, TenantID: tenantID}, Clients: []string{}}
+		}
+		return summaries[name]
+	}
+	for _, site := range sites {
+		summary(site.Name).Site = site
+	}
+	for _, c := range clients {
+		sum := summary(c.Site)
+		sum.Clients = append(sum.Clients, c.ID)
+		if c.IsOnline {
+			sum.Online++
+		}
+	}
+
+	rows, err := s.db.Query(`
+		SELECT c.site, h.target_url, COUNT(*),
+		       SUM(CASE WHEN h.success THEN 1 ELSE 0 END),
+		       COALESCE(SUM(CASE WHEN h.success THEN h.latency ELSE 0 END), 0)
+		FROM client_history h
+		JOIN clients c ON c.id = h.client_id AND c.tenant_id = h.tenant_id
+		WHERE h.tenant_id = ? AND h.timestamp > ? AND h.maintenance = 0 AND h.target_url != ''
+		GROUP BY c.site, h.target_url`,
+		tenantID, since)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	overall := make(map[string]*siteStatsAccumulator)
+	byTarget := make(map[string]map[string]*siteStatsAccumulator)
+	for rows.Next() {
+		var site, targetURL string
+		var samples, successes int
+		var latencySum float64
+		if err := rows.Scan(&site, &targetURL, &samples, &successes, &latencySum); err != nil {
+			log.Printf("Erreur de scan des statistiques par site: %v", err)
+			continue
+		}
+		summary(site)
+		if overall[site] == nil {
+			overall[site] = &siteStatsAccumulator{}
+		}
+		overall[site].add(samples, successes, latencySum)
+		if byTarget[targetURL] == nil {
+			byTarget[targetURL] = make(map[string]*siteStatsAccumulator)
+		}
+		if byTarget[targetURL][site] == nil {
+			byTarget[targetURL][site] = &siteStatsAccumulator{}
+		}
+		byTarget[targetURL][site].add(samples, successes, latencySum)
+	}
+
+	for name, sum := range summaries {
+		if acc, ok := overall[name]; ok {
+			sum.Stats = acc.stats(name)
+		} else {
+			sum.Stats = SiteStats{Site: name}
+		}
+		report.Sites = append(report.Sites, *sum)
+	}
+	// Named sites first, alphabetically, clients without a site last
+	sort.Slice(report.Sites, func(i, j int) bool {
+		a, b := report.Sites[i].Site.Name, report.Sites[j].Site.Name
+		if (a == noSiteName) != (b == noSiteName) {
+			return b == noSiteName
+		}
+		return a < b
+	})
+
+	for targetURL, accs := range byTarget {
+		cmp := TargetSiteComparison{TargetURL: targetURL, Sites: []SiteStats{}}
+		var fastest float64
+		for site, acc := range accs {
+			st := acc.stats(site)
+			cmp.Sites = append(cmp.Sites, st)
+			if st.AvgLatencyMs > 0 && (fastest == 0 || st.AvgLatencyMs < fastest) {
+				cmp.FastestSite, fastest = site, st.AvgLatencyMs
+			}
+		}
+		// Each site's latency relative to the fastest one
+		for i := range cmp.Sites {
+			if fastest > 0 && cmp.Sites[i].AvgLatencyMs > 0 {
+				cmp.Sites[i].RelativeLatency = cmp.Sites[i].AvgLatencyMs / fastest
+			}
+		}
+		sort.Slice(cmp.Sites, func(i, j int) bool { return cmp.Sites[i].Site < cmp.Sites[j].Site })
+		report.Targets = append(report.Targets, cmp)
+	}
+	sort.Slice(report.Targets, func(i, j int) bool { return report.Targets[i].TargetURL < report.Targets[j].TargetURL })
+	return report, nil
+}
+
+// sitePeriod reads the ?hours= period of the site report, 24 hours by default.
+func sitePeriod(r *http.Request) int {
+	if h, err := strconv.Atoi(r.URL.Query().Get("hours")); err == nil && h > 0 && h <= 24*31 {
+		return h
+	}
+	return 24
+}
+
+// HandleSites lists the sites of the tenant (GET, or GET ?report=1&hours= for
+// their health and latency), creates or updates (POST) and deletes (DELETE
+// ?name=) them. Changes require the operator role.
+func (s *Server) HandleSites(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	tenantID := s.requestTenant(r)
+	if r.Method != http.MethodGet && !user.hasRole(RoleOperator) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("report") != "" {
+			report, err := s.getSiteReport(tenantID, time.Now().Add(-time.Duration(sitePeriod(r))*time.Hour))
+			if err != nil {
+				log.Printf("Erreur de calcul du rapport par site: %v", err)
+				http.Error(w, "Erreur de calcul du rapport par site", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, report)
+			return
+		}
+		sites, err := s.listSites(tenantID)
+		if err != nil {
+			log.Printf("Erreur de récupération des sites: %v", err)
+			http.Error(w, "Erreur de récupération des sites", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, sites)
+
+	case http.MethodPost:
+		var site Site
+		if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		site.TenantID = tenantID
+		if err := s.saveSite(&site); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "site_save", site.Name, "")
+		writeJSON(w, http.StatusOK, site)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		res, err := s.db.Exec(`DELETE FROM sites WHERE tenant_id = ? AND name = ?`, tenantID, name)
+		if err != nil {
+			log.Printf("Erreur de suppression du site %s: %v", name, err)
+			http.Error(w, "Erreur de suppression du site", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Site introuvable", http.StatusNotFound)
+			return
+		}
+		s.forgetSiteSubnets(tenantID)
+		s.audit(r, tenantID, user.Username, "site_delete", name, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSitesPage renders the health and latency of the tenant by site, with
+// the latency of each target compared across sites.
+func (s *Server) HandleSitesPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID := s.requestTenant(r)
+	hours := sitePeriod(r)
+	report, err := s.getSiteReport(tenantID, time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		log.Printf("Erreur de calcul du rapport par site: %v", err)
+		http.Error(w, "Erreur de calcul du rapport par site", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.New("sites.html").ParseFiles("templates/sites.html")
+	if err != nil {
+		log.Printf("Erreur de chargement du template des sites: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := struct {
+		CurrentUser   *User
+		CurrentTenant string
+		Hours         int
+		Report        SiteReport
+	}{
+		CurrentUser:   userFromContext(r.Context()),
+		CurrentTenant: tenantID,
+		Hours:         hours,
+		Report:        report,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := tmpl.Execute(w, pageData); err != nil {
+		log.Printf("Erreur lors de l'exécution du template des sites: %v", err)
+	}
+}
\ No newline at end of file
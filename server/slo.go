@@ -0,0 +1,418 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sloBurnWindows are the windows burn rates are computed over, from the
+// shortest to the longest.
+var sloBurnWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"1d", 24 * time.Hour},
+	{"3d", 72 * time.Hour},
+}
+
+// sloForecastWindow is the burn rate window the budget exhaustion forecast
+// relies on.
+const sloForecastWindow = 6 * time.Hour
+
+var errUnknownSLO = errors.New("SLO inconnu")
+
+// validateSLO checks an SLO definition and applies the defaults.
+func validateSLO(slo *SLO) error {
+	if slo.ClientID == "" {
+		return errors.New("client_id requis")
+	}
+	if slo.Objective <= 0 || slo.Objective >= 100 {
+		return errors.New("objective doit être entre 0 et 100 exclus")
+	}
+	if slo.LatencyMs < 0 {
+		return errors.New("latency_ms invalide")
+	}
+	if slo.WindowDays == 0 {
+		slo.WindowDays = 28
+	}
+	if slo.WindowDays < 1 || slo.WindowDays > 365 {
+		return errors.New("window_days doit être entre 1 et 365")
+	}
+	if slo.Name == "" {
+		slo.Name = fmt.Sprintf("%s %g%%", slo.ClientID, slo.Objective)
+	}
+	return nil
+}
+
+// listSLOs returns the SLOs of a tenant.
+func (s *Server) listSLOs(tenantID string) ([]SLO, error) {
+	rows, err := s.db.Query(`
+		SELECT id, tenant_id, client_id, name, objective, latency_ms, window_days, created_at
+		FROM slos WHERE tenant_id = ?
+		ORDER BY client_id, name`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slos := []SLO{}
+	for rows.Next() {
+		var slo SLO
+		if err := rows.Scan(&slo.ID, &slo.TenantID, &slo.ClientID, &slo.Name, &slo.Objective, &slo.LatencyMs, &slo.WindowDays, &slo.CreatedAt); err != nil {
+			log.Printf("Erreur de scan des SLO: %v", err)
+			continue
+		}
+		slos = append(slos, slo)
+	}
+	return slos, nil
+}
+
+// getSLO returns an SLO of a tenant by ID.
+func (s *Server) getSLO(tenantID string, id int64) (SLO, error) {
+	var slo SLO
+	err := s.db.QueryRow(`
+		SELECT id, tenant_id, client_id, name, objective, latency_ms, window_days, created_at
+		FROM slos WHERE id = ? AND tenant_id = ?`, id, tenantID).
+		Scan(&slo.ID, &slo.TenantID, &slo.ClientID, &slo.Name, &slo.Objective, &slo.LatencyMs, &slo.WindowDays, &slo.CreatedAt)
+	if err == sql.ErrNoRows {
+		return slo, errUnknownSLO
+	}
+	return slo, err
+}
+
+// saveSLO creates an SLO, or updates it when it has an ID.
+func (s *Server) saveSLO(slo *SLO) error {
+	if err := validateSLO(slo); err != nil {
+		return err
+	}
+
+	if slo.ID == 0 {
+		slo.CreatedAt = time.Now()
+		res, err := s.db.Exec(`
+			INSERT INTO slos (tenant_id, client_id, name, objective, latency_ms, window_days, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			slo.TenantID, slo.ClientID, slo.Name, slo.Objective, slo.LatencyMs, slo.WindowDays, slo.CreatedAt)
+		if err != nil {
+			return err
+		}
+		slo.ID, err = res.LastInsertId()
+		return err
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE slos SET client_id = ?, name = ?, objective = ?, latency_ms = ?, window_days = ?
+		WHERE id = ? AND tenant_id = ?`,
+		slo.ClientID, slo.Name, slo.Objective, slo.LatencyMs, slo.WindowDays, slo.ID, slo.TenantID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errUnknownSLO
+	}
+	return nil
+}
+
+// sloEvents counts the checks of an SLO's client since a given time and how
+// many of them were good: successful and, with a latency limit, fast enough.
+// Samples received during maintenance are left out.
+func (s *Server) sloEvents(slo SLO, since time.Time) (total, good int, err error) {
+	err = s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN success AND (? <= 0 OR latency <= ?) THEN 1 ELSE 0 END), 0)
+		FROM client_history
+		WHERE tenant_id = ? AND client_id = ? AND timestamp > ? AND maintenance = 0`,
+		slo.LatencyMs, slo.LatencyMs, slo.TenantID, slo.ClientID, since).Scan(&total, &good)
+	return total, good, err
+}
+
+// burnRate returns how fast an SLO consumes its error budget over a window: 1
+// means the budget would last exactly the SLO window.
+func (s *Server) burnRate(slo SLO, window time.Duration, now time.Time) (float64, int, error) {
+	total, good, err := s.sloEvents(slo, now.Add(-window))
+	if err != nil || total == 0 {
+		return 0, total, err
+	}
+	badRatio := float64(total-good) / float64(total)
+	return badRatio / (1 - slo.Objective/100), total, nil
+}
+
+// getSLOStatus computes the indicator, remaining error budget, burn rates and
+// exhaustion forecast of an SLO. A window longer than the history retention of
+// the tenant is shortened to it, as older checks are no longer kept.
+func (s *Server) getSLOStatus(slo SLO) (SLOStatus, error) {
+	now := time.Now()
+	status := SLOStatus{SLO: slo, EffectiveWindowDays: slo.WindowDays, BudgetRemaining: 1, BurnRates: []BurnRate{}}
+	if retention := s.retentionDays(slo.TenantID); retention < slo.WindowDays {
+		status.EffectiveWindowDays = retention
+	}
+	window := time.Duration(status.EffectiveWindowDays) * 24 * time.Hour
+
+	total, good, err := s.sloEvents(slo, now.Add(-window))
+	if err != nil {
+		return status, err
+	}
+	status.TotalEvents = total
+	status.GoodEvents = good
+	if total > 0 {
+		status.SLI = float64(good) / float64(total) * 100
+		allowedBad := float64(total) * (1 - slo.Objective/100)
+		status.BudgetRemaining = 1 - float64(total-good)/allowedBad
+	}
+
+	forecastRate := 0.0
+	for _, w := range sloBurnWindows {
+		rate, events, err := s.burnRate(slo, w.duration, now)
+		if err != nil {
+			return status, err
+		}
+		status.BurnRates = append(status.BurnRates, BurnRate{Window: w.name, Rate: rate, Events: events})
+		if w.duration == sloForecastWindow {
+			forecastRate = rate
+		}
+	}
+
+	// At burn rate b, the whole budget lasts window/b
+	if forecastRate > 0 && status.BudgetRemaining > 0 {
+		left := time.Duration(status.BudgetRemaining * float64(window) / forecastRate)
+		at := now.Add(left)
+		status.ExhaustionAt = &at
+	}
+	return status, nil
+}
+
+// getSLOStatuses returns the status of every SLO of a tenant.
+func (s *Server) getSLOStatuses(tenantID string) ([]SLOStatus, error) {
+	slos, err := s.listSLOs(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []SLOStatus{}
+	for _, slo := range slos {
+		status, err := s.getSLOStatus(slo)
+		if err != nil {
+			log.Printf("Erreur de calcul du SLO %d: %v", slo.ID, err)
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// evaluateSLOBurnRate finds the SLOs burning their error budget faster than
+// "threshold" over both a long and a short window, the usual multi-window
+// burn rate alert: the long window ignores blips, the short one resolves the
+// alert as soon as the burn stops.
+func (s *Server) evaluateSLOBurnRate(rule AlertRule) ([]alertFinding, error) {
+	threshold := rule.param("threshold", 14.4)
+	long := time.Duration(rule.param("long_minutes", 60)) * time.Minute
+	short := time.Duration(rule.param("short_minutes", 5)) * time.Minute
+	sloID := int64(rule.param("slo_id", 0))
+
+	slos, err := s.listSLOs(rule.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []alertFinding
+	now := time.Now()
+	for _, slo := range slos {
+		if (sloID != 0 && slo.ID != sloID) || (rule.ClientID != "" && slo.ClientID != rule.ClientID) {
+			continue
+		}
+		longRate, _, err := s.burnRate(slo, long, now)
+		if err != nil {
+			return nil, err
+		}
+		shortRate, _, err := s.burnRate(slo, short, now)
+		if err != nil {
+			return nil, err
+		}
+		if longRate < threshold || shortRate < threshold {
+			continue
+		}
+		findings = append(findings, alertFinding{
+			ClientID: slo.ClientID,
+			Target:   fmt.Sprintf("slo:%d", slo.ID),
+			Message: fmt.Sprintf("SLO %s : budget d'erreur consommé à %.1fx sur %s et %.1fx sur %s (seuil %.1fx)",
+				slo.Name, longRate, long, shortRate, short, threshold),
+		})
+	}
+	return findings, nil
+}
+
+// HandleSLOs lists the SLOs of the tenant with their status (GET, ?id= for
+// one), creates (POST), updates (PUT ?id=) and deletes (DELETE ?id=) them.
+// Changes require the operator role.
+func (s *Server) HandleSLOs(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	tenantID := s.requestTenant(r)
+	if r.Method != http.MethodGet && !user.hasRole(RoleOperator) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if idStr := r.URL.Query().Get("id"); idStr != "" {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				http.Error(w, "id invalide", http.StatusBadRequest)
+				return
+			}
+			slo, err := s.getSLO(tenantID, id)
+			if err == errUnknownSLO {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			var status SLOStatus
+			if err == nil {
+				status, err = s.getSLOStatus(slo)
+			}
+			if err != nil {
+				log.Printf("Erreur de calcul du SLO %d: %v", id, err)
+				http.Error(w, "Erreur de récupération des SLO", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, status)
+			return
+		}
+		statuses, err := s.getSLOStatuses(tenantID)
+		if err != nil {
+			log.Printf("Erreur de récupération des SLO: %v", err)
+			http.Error(w, "Erreur de récupération des SLO", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, statuses)
+
+	case http.MethodPost, http.MethodPut:
+		var slo SLO
+		if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		slo.ID = 0
+		if r.Method == http.MethodPut {
+			id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+			if err != nil {
+				http.Error(w, "id invalide", http.StatusBadRequest)
+				return
+			}
+			slo.ID = id
+		}
+		slo.TenantID = tenantID
+
+		if err := s.saveSLO(&slo); err != nil {
+			if err == errUnknownSLO {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "slo_save", strconv.FormatInt(slo.ID, 10),
+			fmt.Sprintf("client=%s objective=%g latency_ms=%g window_days=%d", slo.ClientID, slo.Objective, slo.LatencyMs, slo.WindowDays))
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, slo)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		res, err := s.db.Exec(`DELETE FROM slos WHERE id = ? AND tenant_id = ?`, id, tenantID)
+		if err != nil {
+			log.Printf("Erreur de suppression du SLO %d: %v", id, err)
+			http.Error(w, "Erreur de suppression du SLO", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, errUnknownSLO.Error(), http.StatusNotFound)
+			return
+		}
+		s.audit(r, tenantID, user.Username, "slo_delete", strconv.FormatInt(id, 10), "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSLOPage renders the overview of the tenant's SLOs.
+func (s *Server) HandleSLOPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID := s.requestTenant(r)
+	statuses, err := s.getSLOStatuses(tenantID)
+	if err != nil {
+		log.Printf("Erreur de récupération des SLO: %v", err)
+		http.Error(w, "Erreur de récupération des SLO", http.StatusInternalServerError)
+		return
+	}
+	alerts, err := s.listAlerts(tenantID, AlertStateFiring, 200)
+	if err != nil {
+		log.Printf("Erreur de récupération des alertes: %v", err)
+	}
+	var sloAlerts []Alert
+	for _, a := range alerts {
+		if a.Type == AlertTypeSLOBurnRate {
+			sloAlerts = append(sloAlerts, a)
+		}
+	}
+
+	tmpl, err := template.New("slos.html").
+		Funcs(template.FuncMap{
+			"formatTime": func(t time.Time) string {
+				return t.Format("02/01/2006 15:04")
+			},
+			"percent": func(f float64) float64 {
+				return f * 100
+			},
+			"budgetWidth": func(f float64) float64 {
+				if f < 0 {
+					return 0
+				}
+				return f * 100
+			},
+		}).
+		ParseFiles("templates/slos.html")
+	if err != nil {
+		log.Printf("Erreur de chargement du template des SLO: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := struct {
+		CurrentUser   *User
+		CurrentTenant string
+		SLOs          []SLOStatus
+		Alerts        []Alert
+	}{
+		CurrentUser:   userFromContext(r.Context()),
+		CurrentTenant: tenantID,
+		SLOs:          statuses,
+		Alerts:        sloAlerts,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := tmpl.Execute(w, pageData); err != nil {
+		log.Printf("Erreur lors de l'exécution du template des SLO: %v", err)
+	}
+}
\ No newline at end of file
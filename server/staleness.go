@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Client states, from the reporting delay expressed in expected intervals.
+const (
+	ClientStateOnline  = "online"
+	ClientStateLate    = "late"    // More than lateMultiple intervals without result
+	ClientStateStale   = "stale"   // More than staleMultiple intervals
+	ClientStateOffline = "offline" // More than offlineMultiple intervals
+)
+
+const (
+	lateMultiple    = 1.5
+	staleMultiple   = 3
+	offlineMultiple = 10
+
+	// defaultReportInterval is used until a client's interval is declared or learned.
+	defaultReportInterval = 60
+	// intervalLearningSamples is the number of recent results the interval is learned from.
+	intervalLearningSamples = 21
+)
+
+// clientState returns the state of a client that last reported since ago and
+// the number of results it missed meanwhile.
+func clientState(since time.Duration, intervalSeconds int) (string, int) {
+	interval := time.Duration(intervalSeconds) * time.Second
+	missed := 0
+	if since > interval {
+		missed = int(since/interval) - 1
+	}
+	switch ratio := float64(since) / float64(interval); {
+	case ratio > offlineMultiple:
+		return ClientStateOffline, missed
+	case ratio > staleMultiple:
+		return ClientStateStale, missed
+	case ratio > lateMultiple:
+		return ClientStateLate, missed
+	default:
+		return ClientStateOnline, missed
+	}
+}
+
+// expectedInterval returns the interval a client is expected to report at and
+// where it comes from: its check definition, the probe, the median spacing of
+// its recent results, or the default.
+func (s *Server) expectedInterval(tenantID, clientID string, checkInterval, declaredInterval int) (int, string) {
+	if checkInterval > 0 {
+		return checkInterval, "check"
+	}
+	if declaredInterval > 0 {
+		return declaredInterval, "probe"
+	}
+	if learned := s.learnInterval(tenantID, clientID); learned > 0 {
+		return learned, "learned"
+	}
+	return defaultReportInterval, "default"
+}
+
+// learnInterval returns the median spacing in seconds of the client's recent
+// results, or 0 when there are too few of them.
+func (s *Server) learnInterval(tenantID, clientID string) int {
+	rows, err := s.db.Query(`
+		SELECT timestamp FROM client_history
+		WHERE tenant_id = ? AND client_id = ?
+		ORDER BY timestamp DESC
+		LIMIT ?`, tenantID, clientID, intervalLearningSamples)
+	if err != nil {
+		log.Printf("Erreur d'apprentissage de l'intervalle du client %s: %v", clientID, err)
+		return 0
+	}
+	defer rows.Close()
+
+	var previous time.Time
+	var gaps []float64
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			continue
+		}
+		if !previous.IsZero() {
+			gaps = append(gaps, previous.Sub(ts).Seconds())
+		}
+		previous = ts
+	}
+	if len(gaps) < 5 {
+		return 0
+	}
+	sort.Float64s(gaps)
+	median := gaps[len(gaps)/2]
+	if median < 1 {
+		return 1
+	}
+	return int(median + 0.5)
+}
+
+// evaluateClientStale finds the clients that have not reported for more than
+// "multiple" expected intervals (staleMultiple by default). Probes that only
+// sent heartbeats so far have no result to be late on and are left out.
+func (s *Server) evaluateClientStale(rule AlertRule) ([]alertFinding, error) {
+	multiple := rule.param("multiple", staleMultiple)
+	clients, err := s.getClientStatuses(rule.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []alertFinding
+	now := time.Now()
+	for _, c := range clients {
+		if (rule.ClientID != "" && c.ID != rule.ClientID) || c.LastSeen.IsZero() {
+			continue
+		}
+		since := now.Sub(c.LastSeen)
+		if since.Seconds() <= multiple*float64(c.Interval) {
+			continue
+		}
+		findings = append(findings, alertFinding{
+			ClientID: c.ID,
+			Target:   c.TargetURL,
+			Message: fmt.Sprintf("Aucun résultat de %s depuis %s (%d attendus, intervalle %ds)",
+				c.ID, since.Round(time.Second), c.MissedReports, c.Interval),
+		})
+	}
+	return findings, nil
+}
\ No newline at end of file
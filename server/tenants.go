@@ -0,0 +1,290 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// defaultTenantID owns the data that existed before tenants were introduced.
+const defaultTenantID = "default"
+
+// tenantCookieName remembers the tenant selected by users spanning all tenants.
+const tenantCookieName = "nm_tenant"
+
+// defaultRetentionDays is the history retention of tenants that set none.
+const defaultRetentionDays = 7
+
+var (
+	errClientOtherTenant   = errors.New("client appartenant à un autre tenant")
+	errClientQuotaExceeded = errors.New("quota de clients du tenant atteint")
+	errUnknownTenant       = errors.New("tenant inconnu")
+)
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// getTenant returns a tenant by ID.
+func (s *Server) getTenant(id string) (Tenant, error) {
+	var t Tenant
+	var name sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, name, retention_days, max_clients, gap_policy, created_at
+		FROM tenants WHERE id = ?`,
+		id).Scan(&t.ID, &name, &t.RetentionDays, &t.MaxClients, &t.GapPolicy, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return t, errUnknownTenant
+	}
+	t.Name = name.String
+	return t, err
+}
+
+// listTenants returns every tenant.
+func (s *Server) listTenants() ([]Tenant, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, retention_days, max_clients, gap_policy, created_at
+		FROM tenants
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenants := []Tenant{}
+	for rows.Next() {
+		var t Tenant
+		var name sql.NullString
+		if err := rows.Scan(&t.ID, &name, &t.RetentionDays, &t.MaxClients, &t.GapPolicy, &t.CreatedAt); err != nil {
+			log.Printf("Erreur de scan des tenants: %v", err)
+			continue
+		}
+		t.Name = name.String
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+// saveTenant creates or updates a tenant.
+func (s *Server) saveTenant(t Tenant) error {
+	_, err := s.db.Exec(`
+		INSERT INTO tenants (id, name, retention_days, max_clients, gap_policy, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			retention_days = excluded.retention_days,
+			max_clients = excluded.max_clients,
+			gap_policy = excluded.gap_policy`,
+		t.ID, t.Name, t.RetentionDays, t.MaxClients, t.GapPolicy, time.Now())
+	return err
+}
+
+// deleteTenant removes a tenant that no longer owns clients or users.
+func (s *Server) deleteTenant(id string) error {
+	var owned int
+	err := s.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM clients WHERE tenant_id = ?) +
+		       (SELECT COUNT(*) FROM users WHERE tenant_id = ?)`,
+		id, id).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if owned > 0 {
+		return errors.New("le tenant possède encore des clients ou des utilisateurs")
+	}
+	if _, err := s.db.Exec(`UPDATE probe_tokens SET revoked_at = ? WHERE tenant_id = ? AND revoked_at IS NULL`, time.Now(), id); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`DELETE FROM tenants WHERE id = ?`, id)
+	return err
+}
+
+// admitClient checks that a tenant may report data for a client: the client
+// must not belong to another tenant and new clients must fit in the quota.
+func (s *Server) admitClient(tenantID, clientID string) error {
+	var owner string
+	err := s.db.QueryRow(`SELECT tenant_id FROM clients WHERE id = ?`, clientID).Scan(&owner)
+	if err == nil {
+		if owner != tenantID {
+			return errClientOtherTenant
+		}
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	tenant, err := s.getTenant(tenantID)
+	if err != nil {
+		return err
+	}
+	if tenant.MaxClients <= 0 {
+		return nil
+	}
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM clients WHERE tenant_id = ?`, tenantID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= tenant.MaxClients {
+		return errClientQuotaExceeded
+	}
+	return nil
+}
+
+// retentionDays returns the number of days of history kept for a tenant.
+func (s *Server) retentionDays(tenantID string) int {
+	if t, err := s.getTenant(tenantID); err == nil && t.RetentionDays > 0 {
+		return t.RetentionDays
+	}
+	return defaultRetentionDays
+}
+
+// purgeExpiredHistory deletes each tenant's history older than its retention.
+func (s *Server) purgeExpiredHistory() error {
+	tenants, err := s.listTenants()
+	if err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		days := t.RetentionDays
+		if days <= 0 {
+			days = defaultRetentionDays
+		}
+		_, err := s.db.Exec(`
+			DELETE FROM client_history
+			WHERE tenant_id = ? AND timestamp < ?`,
+			t.ID, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Exec(`
+			DELETE FROM path_traces
+			WHERE tenant_id = ? AND timestamp < ?`,
+			t.ID, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Exec(`
+			DELETE FROM phase_shifts
+			WHERE tenant_id = ? AND changed_at < ?`,
+			t.ID, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Exec(`
+			DELETE FROM remote_ips
+			WHERE tenant_id = ? AND last_seen < ?`,
+			t.ID, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Exec(`
+			DELETE FROM ip_changes
+			WHERE tenant_id = ? AND changed_at < ?`,
+			t.ID, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestTenant returns the tenant a dashboard request operates on. Users
+// bound to a tenant always get theirs; users spanning all tenants pick one
+// with ?tenant= (remembered in a cookie) and fall back to the default tenant.
+func (s *Server) requestTenant(r *http.Request) string {
+	if u := userFromContext(r.Context()); u != nil && u.TenantID != "" {
+		return u.TenantID
+	}
+	if t := r.URL.Query().Get("tenant"); t != "" {
+		return t
+	}
+	if cookie, err := r.Cookie(tenantCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	return defaultTenantID
+}
+
+// adminTenantScope returns the tenant an admin request is restricted to, or
+// "" when a user spanning all tenants did not ask for a specific one.
+func adminTenantScope(r *http.Request) string {
+	if u := userFromContext(r.Context()); u != nil && u.TenantID != "" {
+		return u.TenantID
+	}
+	return r.URL.Query().Get("tenant")
+}
+
+// HandleAdminTenants lists (GET), creates or updates (POST) and deletes
+// (DELETE ?id=) tenants. Only users spanning all tenants may use it.
+func (s *Server) HandleAdminTenants(w http.ResponseWriter, r *http.Request) {
+	actor := userFromContext(r.Context())
+	if actor.TenantID != "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tenants, err := s.listTenants()
+		if err != nil {
+			log.Printf("Erreur de récupération des tenants: %v", err)
+			http.Error(w, "Erreur de récupération des tenants", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, tenants)
+
+	case http.MethodPost:
+		var t Tenant
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if !tenantIDPattern.MatchString(t.ID) {
+			http.Error(w, "id invalide (minuscules, chiffres, - et _)", http.StatusBadRequest)
+			return
+		}
+		if t.RetentionDays <= 0 {
+			t.RetentionDays = defaultRetentionDays
+		}
+		if t.MaxClients < 0 {
+			t.MaxClients = 0
+		}
+		switch t.GapPolicy {
+		case "":
+			t.GapPolicy = GapPolicyIgnore
+		case GapPolicyIgnore, GapPolicyDown:
+		default:
+			http.Error(w, "gap_policy invalide (ignore ou down)", http.StatusBadRequest)
+			return
+		}
+		if err := s.saveTenant(t); err != nil {
+			log.Printf("Erreur d'enregistrement du tenant %s: %v", t.ID, err)
+			http.Error(w, "Erreur d'enregistrement du tenant", http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, "", actor.Username, "tenant_save", t.ID, "")
+		if saved, err := s.getTenant(t.ID); err == nil {
+			t = saved
+		}
+		writeJSON(w, http.StatusOK, t)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" || id == defaultTenantID {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		if err := s.deleteTenant(id); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.audit(r, "", actor.Username, "tenant_delete", id, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsReloadInterval is how often the certificate files are checked for changes.
+const tlsReloadInterval = 10 * time.Second
+
+// fileReloader reloads a value built from files whenever one of them changes
+// on disk. The last good value is kept if a reload fails, so a half-written
+// renewal never takes the listener down.
+type fileReloader struct {
+	files []string
+	load  func() (interface{}, error)
+
+	mu        sync.Mutex
+	value     interface{}
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newFileReloader(load func() (interface{}, error), files ...string) (*fileReloader, error) {
+	fr := &fileReloader{files: files, load: load}
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	fr.value = value
+	fr.modTime = fr.latestModTime()
+	fr.lastCheck = time.Now()
+	return fr, nil
+}
+
+func (fr *fileReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range fr.files {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// get returns the current value, reloading it if the files changed.
+func (fr *fileReloader) get() interface{} {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if time.Since(fr.lastCheck) < tlsReloadInterval {
+		return fr.value
+	}
+	fr.lastCheck = time.Now()
+
+	modTime := fr.latestModTime()
+	if !modTime.After(fr.modTime) {
+		return fr.value
+	}
+
+	value, err := fr.load()
+	if err != nil {
+		log.Printf("Erreur de rechargement de %v, conservation de la version précédente: %v", fr.files, err)
+		return fr.value
+	}
+	fr.value = value
+	fr.modTime = modTime
+	log.Printf("Fichiers TLS rechargés: %v", fr.files)
+	return fr.value
+}
+
+// NewTLSConfig builds the listener TLS configuration. The server certificate
+// is reloaded when certFile or keyFile change. When clientCAFile is set,
+// client certificates signed by one of its CAs are verified if presented; they
+// are not required at the TLS level so that browsers can still reach the
+// dashboard, and /data decides whether to require them.
+func NewTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("certificat et clé requis pour TLS")
+	}
+
+	certs, err := newFileReloader(func() (interface{}, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}, certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("chargement du certificat serveur: %w", err)
+	}
+
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.get().(*tls.Certificate), nil
+		},
+	}
+	if clientCAFile == "" {
+		return base, nil
+	}
+
+	cas, err := newFileReloader(func() (interface{}, error) {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("aucun certificat d'AC valide dans " + clientCAFile)
+		}
+		return pool, nil
+	}, clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("chargement des AC clientes: %w", err)
+	}
+
+	base.ClientAuth = tls.VerifyClientCertIfGiven
+	base.ClientCAs = cas.get().(*x509.CertPool)
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = cas.get().(*x509.CertPool)
+		return cfg, nil
+	}
+	return base, nil
+}
+
+// clientIDFromCertificate derives a ClientID from a verified client
+// certificate: the subject CN, or else the first DNS or URI SAN.
+func clientIDFromCertificate(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return ""
+}
\ No newline at end of file
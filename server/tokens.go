@@ -0,0 +1,286 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probeTokenPrefix makes probe tokens recognisable in configuration files and logs.
+const probeTokenPrefix = "nmp_"
+
+var (
+	errMissingToken      = errors.New("jeton absent")
+	errInvalidToken      = errors.New("jeton invalide ou révoqué")
+	errMissingClientCert = errors.New("certificat client requis")
+	errInvalidClientCert = errors.New("certificat client sans CN ni SAN exploitable")
+)
+
+// generateToken returns a random token with the given prefix.
+func generateToken(prefix string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// hashToken returns the hex SHA-256 digest under which a token is stored.
+// Tokens are long random values, so a fast unsalted hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken extracts the token from the Authorization header, falling back
+// to X-Probe-Token for probes that cannot set Authorization.
+func bearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			return strings.TrimSpace(auth[7:])
+		}
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-Probe-Token"))
+}
+
+// createProbeToken issues a new token bound to a tenant's clientID. The
+// plaintext token is only available in the returned value.
+func (s *Server) createProbeToken(tenantID, clientID, name string) (ProbeToken, error) {
+	if _, err := s.getTenant(tenantID); err != nil {
+		return ProbeToken{}, err
+	}
+	var owner string
+	err := s.db.QueryRow(`SELECT tenant_id FROM clients WHERE id = ?`, clientID).Scan(&owner)
+	if err == nil && owner != tenantID {
+		return ProbeToken{}, errClientOtherTenant
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return ProbeToken{}, err
+	}
+
+	plain, err := generateToken(probeTokenPrefix)
+	if err != nil {
+		return ProbeToken{}, err
+	}
+
+	now := time.Now()
+	res, err := s.db.Exec(`
+		INSERT INTO probe_tokens (tenant_id, client_id, name, token_hash, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		tenantID, clientID, name, hashToken(plain), now)
+	if err != nil {
+		return ProbeToken{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return ProbeToken{}, err
+	}
+
+	return ProbeToken{ID: id, TenantID: tenantID, ClientID: clientID, Name: name, CreatedAt: now, Token: plain}, nil
+}
+
+// listProbeTokens returns the tokens of a tenant (all tenants when empty),
+// optionally restricted to one client.
+func (s *Server) listProbeTokens(tenantID, clientID string) ([]ProbeToken, error) {
+	query := `
+		SELECT id, tenant_id, client_id, name, created_at, last_used_at, revoked_at
+		FROM probe_tokens
+		WHERE (? = '' OR tenant_id = ?)`
+	args := []interface{}{tenantID, tenantID}
+	if clientID != "" {
+		query += ` AND client_id = ?`
+		args = append(args, clientID)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []ProbeToken{}
+	for rows.Next() {
+		var t ProbeToken
+		var name sql.NullString
+		var lastUsed, revoked sql.NullTime
+		if err := rows.Scan(&t.ID, &t.TenantID, &t.ClientID, &name, &t.CreatedAt, &lastUsed, &revoked); err != nil {
+			log.Printf("Erreur de scan des jetons de sonde: %v", err)
+			continue
+		}
+		t.Name = name.String
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		if revoked.Valid {
+			t.RevokedAt = &revoked.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// revokeProbeToken marks a token of a tenant (any tenant when empty) as
+// revoked. It reports whether an active token was found.
+func (s *Server) revokeProbeToken(tenantID string, id int64) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE probe_tokens SET revoked_at = ?
+		WHERE id = ? AND revoked_at IS NULL AND (? = '' OR tenant_id = ?)`,
+		time.Now(), id, tenantID, tenantID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// authenticateProbe identifies the probe behind a /data request. A client
+// certificate verified against the configured CAs takes precedence: its
+// CN/SAN is the ClientID and the returned ProbeToken has no ID. Otherwise the
+// bearer token is resolved to its active ProbeToken and its use recorded.
+func (s *Server) authenticateProbe(r *http.Request) (ProbeToken, error) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return s.probeFromCertificate(r.TLS.VerifiedChains[0][0])
+	}
+	if s.requireClientCert {
+		return ProbeToken{}, errMissingClientCert
+	}
+
+	plain := bearerToken(r)
+	if plain == "" {
+		return ProbeToken{}, errMissingToken
+	}
+
+	var t ProbeToken
+	var name sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, tenant_id, client_id, name, created_at
+		FROM probe_tokens
+		WHERE token_hash = ? AND revoked_at IS NULL`,
+		hashToken(plain)).Scan(&t.ID, &t.TenantID, &t.ClientID, &name, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ProbeToken{}, errInvalidToken
+	}
+	if err != nil {
+		return ProbeToken{}, err
+	}
+	t.Name = name.String
+
+	if _, err := s.db.Exec(`UPDATE probe_tokens SET last_used_at = ? WHERE id = ?`, time.Now(), t.ID); err != nil {
+		log.Printf("Erreur de mise à jour de l'utilisation du jeton %d: %v", t.ID, err)
+	}
+	return t, nil
+}
+
+// probeFromCertificate maps a verified client certificate to the identity of
+// the probe. The client keeps its tenant if it is already known, and lands in
+// the default tenant otherwise.
+func (s *Server) probeFromCertificate(cert *x509.Certificate) (ProbeToken, error) {
+	clientID := clientIDFromCertificate(cert)
+	if clientID == "" {
+		return ProbeToken{}, errInvalidClientCert
+	}
+
+	tenantID := defaultTenantID
+	err := s.db.QueryRow(`SELECT tenant_id FROM clients WHERE id = ?`, clientID).Scan(&tenantID)
+	if err != nil && err != sql.ErrNoRows {
+		return ProbeToken{}, err
+	}
+	return ProbeToken{TenantID: tenantID, ClientID: clientID, Name: "certificate:" + cert.Subject.String()}, nil
+}
+
+// isAdminRequest reports whether the request carries the admin API secret.
+func (s *Server) isAdminRequest(r *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+	presented := bearerToken(r)
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(s.adminToken)) == 1
+}
+
+// HandleAdminTokens issues (POST), lists (GET) and revokes (DELETE ?id=) probe tokens.
+func (s *Server) HandleAdminTokens(w http.ResponseWriter, r *http.Request) {
+	actor := userFromContext(r.Context())
+	scope := adminTenantScope(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := s.listProbeTokens(scope, r.URL.Query().Get("client_id"))
+		if err != nil {
+			log.Printf("Erreur de récupération des jetons de sonde: %v", err)
+			http.Error(w, "Erreur de récupération des jetons", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, tokens)
+
+	case http.MethodPost:
+		var req struct {
+			TenantID string `json:"tenant_id"`
+			ClientID string `json:"client_id"`
+			Name     string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.ClientID == "" {
+			http.Error(w, "client_id requis", http.StatusBadRequest)
+			return
+		}
+		tenantID := req.TenantID
+		if actor.TenantID != "" || tenantID == "" {
+			tenantID = scope
+		}
+		if tenantID == "" {
+			tenantID = defaultTenantID
+		}
+		token, err := s.createProbeToken(tenantID, req.ClientID, req.Name)
+		if err == errClientOtherTenant || err == errUnknownTenant {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Erreur d'émission du jeton pour le client %s: %v", req.ClientID, err)
+			http.Error(w, "Erreur d'émission du jeton", http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, token.TenantID, actor.Username, "token_issue", token.ClientID, "id="+strconv.FormatInt(token.ID, 10)+" name="+token.Name)
+		log.Printf("Jeton de sonde %d émis pour le client %s", token.ID, token.ClientID)
+		writeJSON(w, http.StatusCreated, token)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		found, err := s.revokeProbeToken(scope, id)
+		if err != nil {
+			log.Printf("Erreur de révocation du jeton %d: %v", id, err)
+			http.Error(w, "Erreur de révocation du jeton", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Jeton introuvable ou déjà révoqué", http.StatusNotFound)
+			return
+		}
+		s.audit(r, scope, actor.Username, "token_revoke", strconv.FormatInt(id, 10), "")
+		log.Printf("Jeton de sonde %d révoqué", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,483 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles, from least to most privileged. Each role includes the rights of the
+// roles before it.
+const (
+	RoleViewer   = "viewer"   // Dashboard, client list and history APIs
+	RoleOperator = "operator" // + client deletion and check definitions
+	RoleAdmin    = "admin"    // + tokens, users, alert rules and audit trail
+)
+
+var roleRank = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+const (
+	sessionCookieName = "nm_session"
+	sessionDuration   = 12 * time.Hour
+)
+
+var errInvalidCredentials = errors.New("identifiants invalides")
+
+// dummyPasswordHash is compared against when the username does not exist.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+type contextKey int
+
+const userContextKey contextKey = iota
+
+// userFromContext returns the authenticated user attached by RequireRole.
+func userFromContext(ctx context.Context) *User {
+	u, _ := ctx.Value(userContextKey).(*User)
+	return u
+}
+
+// hasRole reports whether the user's role grants at least the given role.
+func (u *User) hasRole(role string) bool {
+	return u != nil && roleRank[u.Role] >= roleRank[role]
+}
+
+// createUser stores a new user of a tenant ("" for all tenants) with a bcrypt
+// hash of its password.
+func (s *Server) createUser(tenantID, username, password, role string) (User, error) {
+	if _, ok := roleRank[role]; !ok {
+		return User{}, errors.New("rôle inconnu: " + role)
+	}
+	if username == "" || len(password) < 8 {
+		return User{}, errors.New("nom d'utilisateur requis et mot de passe de 8 caractères minimum")
+	}
+	if tenantID != "" {
+		if _, err := s.getTenant(tenantID); err != nil {
+			return User{}, err
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+
+	now := time.Now()
+	res, err := s.db.Exec(`
+		INSERT INTO users (username, password_hash, role, created_at, tenant_id)
+		VALUES (?, ?, ?, ?, ?)`,
+		username, string(hash), role, now, tenantID)
+	if err != nil {
+		return User{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return User{}, err
+	}
+	return User{ID: id, Username: username, Role: role, TenantID: tenantID, CreatedAt: now}, nil
+}
+
+// EnsureAdminUser creates an admin account when no user exists yet, so that a
+// fresh installation can be logged into.
+func (s *Server) EnsureAdminUser(username, password string) error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if username == "" || password == "" {
+		log.Println("Aucun utilisateur défini : renseignez MONITOR_ADMIN_USER et MONITOR_ADMIN_PASSWORD pour créer un administrateur")
+		return nil
+	}
+	if _, err := s.createUser("", username, password, RoleAdmin); err != nil {
+		return err
+	}
+	log.Printf("Administrateur initial %s créé", username)
+	return nil
+}
+
+// listUsers returns the user accounts of a tenant, or all of them when
+// tenantID is empty.
+func (s *Server) listUsers(tenantID string) ([]User, error) {
+	rows, err := s.db.Query(`
+		SELECT id, username, role, tenant_id, created_at, disabled
+		FROM users
+		WHERE ? = '' OR tenant_id = ?
+		ORDER BY username`, tenantID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.TenantID, &u.CreatedAt, &u.Disabled); err != nil {
+			log.Printf("Erreur de scan des utilisateurs: %v", err)
+			continue
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// updateUser changes the role, the disabled flag and optionally the password
+// of a user of a tenant (any tenant when empty). Disabling a user or changing
+// its password ends its sessions.
+func (s *Server) updateUser(tenantID string, id int64, role string, disabled bool, password string) error {
+	if _, ok := roleRank[role]; !ok {
+		return errors.New("rôle inconnu: " + role)
+	}
+
+	var userTenant string
+	err := s.db.QueryRow(`SELECT tenant_id FROM users WHERE id = ?`, id).Scan(&userTenant)
+	if err != nil {
+		return err
+	}
+	if tenantID != "" && userTenant != tenantID {
+		return sql.ErrNoRows
+	}
+
+	if password != "" {
+		if len(password) < 8 {
+			return errors.New("mot de passe de 8 caractères minimum")
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`UPDATE users SET password_hash = ? WHERE id = ?`, string(hash), id); err != nil {
+			return err
+		}
+	}
+
+	res, err := s.db.Exec(`UPDATE users SET role = ?, disabled = ? WHERE id = ?`, role, disabled, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if disabled || password != "" {
+		_, err = s.db.Exec(`DELETE FROM sessions WHERE user_id = ?`, id)
+	}
+	return err
+}
+
+// deleteUser removes a user of a tenant (any tenant when empty) and its sessions.
+func (s *Server) deleteUser(tenantID string, id int64) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE user_id IN (SELECT id FROM users WHERE id = ? AND (? = '' OR tenant_id = ?))`, id, tenantID, tenantID); err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`DELETE FROM users WHERE id = ? AND (? = '' OR tenant_id = ?)`, id, tenantID, tenantID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// checkPassword verifies a username/password pair.
+func (s *Server) checkPassword(username, password string) (*User, error) {
+	var u User
+	var hash string
+	err := s.db.QueryRow(`
+		SELECT id, username, password_hash, role, tenant_id, created_at, disabled
+		FROM users WHERE username = ?`,
+		username).Scan(&u.ID, &u.Username, &hash, &u.Role, &u.TenantID, &u.CreatedAt, &u.Disabled)
+	if err == sql.ErrNoRows {
+		// Même coût qu'une vérification réelle pour ne pas révéler les comptes existants
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, errInvalidCredentials
+	}
+	return &u, nil
+}
+
+// createSession opens a session for the user and returns its plaintext token.
+func (s *Server) createSession(userID int64) (string, time.Time, error) {
+	token, err := generateToken("")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expires := now.Add(sessionDuration)
+	_, err = s.db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?)`,
+		hashToken(token), userID, now, expires)
+	return token, expires, err
+}
+
+// sessionUser resolves a session token to its active user.
+func (s *Server) sessionUser(token string) (*User, error) {
+	var u User
+	err := s.db.QueryRow(`
+		SELECT u.id, u.username, u.role, u.tenant_id, u.created_at, u.disabled
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ? AND u.disabled = 0`,
+		hashToken(token), time.Now()).Scan(&u.ID, &u.Username, &u.Role, &u.TenantID, &u.CreatedAt, &u.Disabled)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// authenticateUser identifies the caller from its session cookie, or from the
+// admin API secret for automation.
+func (s *Server) authenticateUser(r *http.Request) *User {
+	if s.isAdminRequest(r) {
+		return &User{Username: "admin-token", Role: RoleAdmin}
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	u, err := s.sessionUser(cookie.Value)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Erreur de vérification de session: %v", err)
+		}
+		return nil
+	}
+	return u
+}
+
+// RequireRole wraps a handler so that it is only served to users holding at
+// least the given role. Pages redirect anonymous visitors to the login form;
+// APIs answer 401.
+func (s *Server) RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := s.authenticateUser(r)
+		if u == nil {
+			if isAPIPath(r.URL.Path) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		if !u.hasRole(role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey, u)))
+	}
+}
+
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/ws"
+}
+
+// localRedirect returns next if it is a path on this server, "/" otherwise.
+// Browsers read "/\host" as "//host", so backslashes are rejected as well.
+func localRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.ContainsRune(next, '\\') {
+		return "/"
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "//") || strings.ContainsRune(u.Path, '\\') {
+		return "/"
+	}
+	return next
+}
+
+// HandleLogin shows the login form (GET) and opens a session (POST).
+func (s *Server) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	next := localRedirect(r.FormValue("next"))
+
+	switch r.Method {
+	case http.MethodGet:
+		s.renderLogin(w, next, "")
+
+	case http.MethodPost:
+		username := r.FormValue("username")
+		u, err := s.checkPassword(username, r.FormValue("password"))
+		if err != nil {
+			if err != errInvalidCredentials {
+				log.Printf("Erreur de vérification des identifiants: %v", err)
+			}
+			s.audit(r, "", username, "login_failed", username, "")
+			w.WriteHeader(http.StatusUnauthorized)
+			s.renderLogin(w, next, "Identifiants invalides")
+			return
+		}
+
+		token, expires, err := s.createSession(u.ID)
+		if err != nil {
+			log.Printf("Erreur de création de session pour %s: %v", u.Username, err)
+			http.Error(w, "Erreur de création de session", http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, u.TenantID, u.Username, "login", u.Username, "")
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    token,
+			Path:     "/",
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, next, http.StatusSeeOther)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleLogout ends the current session.
+func (s *Server) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if _, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, hashToken(cookie.Value)); err != nil {
+			log.Printf("Erreur de suppression de session: %v", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (s *Server) renderLogin(w http.ResponseWriter, next, errorMessage string) {
+	tmpl, err := template.ParseFiles("templates/login.html")
+	if err != nil {
+		log.Printf("Erreur de chargement du template de connexion: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := tmpl.Execute(w, struct{ Next, Error string }{next, errorMessage}); err != nil {
+		log.Printf("Erreur lors de l'exécution du template de connexion: %v", err)
+	}
+}
+
+// HandleAdminUsers lists (GET), creates (POST), updates (PUT ?id=) and
+// deletes (DELETE ?id=) user accounts.
+func (s *Server) HandleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	actor := userFromContext(r.Context())
+	scope := adminTenantScope(r)
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+		TenantID string `json:"tenant_id"`
+		Disabled bool   `json:"disabled"`
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		users, err := s.listUsers(scope)
+		if err != nil {
+			log.Printf("Erreur de récupération des utilisateurs: %v", err)
+			http.Error(w, "Erreur de récupération des utilisateurs", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		// Users bound to a tenant can only create users of their tenant
+		tenantID := req.TenantID
+		if actor.TenantID != "" {
+			tenantID = actor.TenantID
+		}
+		u, err := s.createUser(tenantID, req.Username, req.Password, req.Role)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.audit(r, u.TenantID, actor.Username, "user_create", u.Username, "role="+u.Role)
+		writeJSON(w, http.StatusCreated, u)
+
+	case http.MethodPut:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.updateUser(scope, id, req.Role, req.Disabled, req.Password); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Utilisateur introuvable", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		details := "role=" + req.Role + " disabled=" + strconv.FormatBool(req.Disabled)
+		if req.Password != "" {
+			details += " password_changed"
+		}
+		s.audit(r, scope, actor.Username, "user_update", strconv.FormatInt(id, 10), details)
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		if actor.ID == id {
+			http.Error(w, "Impossible de supprimer son propre compte", http.StatusBadRequest)
+			return
+		}
+		if err := s.deleteUser(scope, id); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Utilisateur introuvable", http.StatusNotFound)
+				return
+			}
+			log.Printf("Erreur de suppression de l'utilisateur %d: %v", id, err)
+			http.Error(w, "Erreur de suppression de l'utilisateur", http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, scope, actor.Username, "user_delete", strconv.FormatInt(id, 10), "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,453 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteWait is the time allowed to write a message to the peer.
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is the time allowed to read the next pong from the peer.
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod must be shorter than wsPongWait.
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// wsMaxMessageSize bounds the size of a request sent by the peer.
+	wsMaxMessageSize = 64 * 1024
+	// wsSendBuffer is the number of outgoing messages queued per connection.
+	wsSendBuffer = 256
+	// wsMaxDropped is the number of pushed updates a consumer may miss in a
+	// row before it is considered too slow and disconnected.
+	wsMaxDropped = 512
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// wsRequest is a message sent by a WebSocket peer.
+type wsRequest struct {
+	Type         string   `json:"type"` // subscribe, unsubscribe, history, anomalies, clients, dashboard, ping
+	RequestID    string   `json:"request_id,omitempty"`
+	ClientIDs    []string `json:"client_ids,omitempty"` // "*" subscribes to every client
+	ClientID     string   `json:"client_id,omitempty"`
+	Duration     string   `json:"duration,omitempty"`
+	SortBy       string   `json:"sort_by,omitempty"`
+	SortOrder    string   `json:"sort_order,omitempty"`
+	Limit        int      `json:"limit,omitempty"`
+	StatusFilter string   `json:"status_filter,omitempty"`
+	MinLatency   float64  `json:"min_latency,omitempty"`
+	MaxLatency   float64  `json:"max_latency,omitempty"`
+	ThresholdMs  float64  `json:"threshold_ms,omitempty"`
+}
+
+// wsMessage is a message pushed to a WebSocket peer, either as the answer to
+// a request (RequestID set) or as a live update.
+type wsMessage struct {
+	Type      string      `json:"type"` // sample, alert, history, anomalies, clients, dashboard, subscribed, lagged, pong, error
+	RequestID string      `json:"request_id,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	ClientIDs []string    `json:"client_ids,omitempty"`
+	Dropped   int         `json:"dropped,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// wsHub keeps track of the connected WebSocket peers and fans out the
+// monitoring samples received on the ingestion path.
+type wsHub struct {
+	mu    sync.RWMutex
+	conns map[*wsConn]struct{}
+}
+
+func newWSHub() *wsHub {
+	return &wsHub{conns: make(map[*wsConn]struct{})}
+}
+
+func (h *wsHub) register(c *wsConn) {
+	h.mu.Lock()
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+}
+
+func (h *wsHub) unregister(c *wsConn) {
+	h.mu.Lock()
+	delete(h.conns, c)
+	h.mu.Unlock()
+}
+
+// publish pushes a freshly stored sample to every peer of the tenant
+// subscribed to its client. It never blocks: slow peers lose updates instead
+// of stalling ingestion.
+func (h *wsHub) publish(tenantID string, data MonitoringData) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.conns) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(wsMessage{Type: "sample", ClientID: data.ClientID, Data: data})
+	if err != nil {
+		log.Printf("Erreur d'encodage du message WebSocket: %v", err)
+		return
+	}
+
+	for c := range h.conns {
+		if c.tenantID == tenantID && c.isSubscribed(data.ClientID) {
+			c.push(payload)
+		}
+	}
+}
+
+// publishAlert pushes an alert that fired or resolved to every peer of its
+// tenant, whatever their subscriptions.
+func (h *wsHub) publishAlert(alert Alert) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.conns) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(wsMessage{Type: "alert", ClientID: alert.ClientID, Data: alert})
+	if err != nil {
+		log.Printf("Erreur d'encodage du message WebSocket: %v", err)
+		return
+	}
+
+	for c := range h.conns {
+		if c.tenantID == alert.TenantID {
+			c.push(payload)
+		}
+	}
+}
+
+// wsConn is a single WebSocket peer.
+type wsConn struct {
+	s        *Server
+	conn     *websocket.Conn
+	tenantID string
+	send     chan []byte
+	done     chan struct{}
+
+	mu            sync.Mutex
+	subscriptions map[string]bool
+	all           bool
+	dropped       int
+	closed        bool
+}
+
+// HandleWebSocket upgrades the connection and serves the bidirectional live
+// dashboard protocol: subscriptions to client samples and on-demand queries.
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Erreur d'ouverture de la connexion WebSocket: %v", err)
+		return
+	}
+
+	c := &wsConn{
+		s:             s,
+		conn:          conn,
+		tenantID:      s.requestTenant(r),
+		send:          make(chan []byte, wsSendBuffer),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]bool),
+	}
+	s.hub.register(c)
+
+	go c.writeLoop()
+	c.readLoop()
+}
+
+func (c *wsConn) isSubscribed(clientID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.all || c.subscriptions[clientID]
+}
+
+// push queues a live update without blocking. Updates that do not fit in the
+// buffer are dropped and counted; the peer is told how many it missed once
+// it catches up, and disconnected if it falls too far behind.
+func (c *wsConn) push(payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+
+	if c.dropped > 0 {
+		notice, _ := json.Marshal(wsMessage{Type: "lagged", Dropped: c.dropped})
+		select {
+		case c.send <- notice:
+			c.dropped = 0
+		default:
+		}
+	}
+
+	select {
+	case c.send <- payload:
+	default:
+		c.dropped++
+		if c.dropped >= wsMaxDropped {
+			log.Printf("Client WebSocket %s trop lent (%d messages perdus), déconnexion", c.conn.RemoteAddr(), c.dropped)
+			c.closeLocked()
+		}
+	}
+}
+
+// reply queues the answer to a request. Answers are not droppable, so this
+// waits for room in the buffer and gives up on the peer after wsWriteWait.
+func (c *wsConn) reply(msg wsMessage) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Erreur d'encodage du message WebSocket: %v", err)
+		return
+	}
+
+	timer := time.NewTimer(wsWriteWait)
+	defer timer.Stop()
+
+	select {
+	case c.send <- payload:
+	case <-c.done:
+	case <-timer.C:
+		log.Printf("Client WebSocket %s ne consomme plus ses réponses, déconnexion", c.conn.RemoteAddr())
+		c.close()
+	}
+}
+
+func (c *wsConn) close() {
+	c.mu.Lock()
+	c.closeLocked()
+	c.mu.Unlock()
+}
+
+func (c *wsConn) closeLocked() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.done)
+}
+
+// readLoop decodes the peer's requests until the connection fails.
+func (c *wsConn) readLoop() {
+	defer func() {
+		c.s.hub.unregister(c)
+		c.close()
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var req wsRequest
+		if err := c.conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Erreur de lecture WebSocket: %v", err)
+			}
+			return
+		}
+		c.handleRequest(req)
+	}
+}
+
+// writeLoop is the only goroutine writing to the connection; it drains the
+// send buffer and keeps the connection alive with pings.
+func (c *wsConn) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			c.conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer"))
+			return
+		}
+	}
+}
+
+// handleRequest dispatches one request from the peer.
+func (c *wsConn) handleRequest(req wsRequest) {
+	switch req.Type {
+	case "subscribe":
+		c.mu.Lock()
+		for _, id := range req.ClientIDs {
+			if id == "*" {
+				c.all = true
+				continue
+			}
+			c.subscriptions[id] = true
+		}
+		c.mu.Unlock()
+		c.reply(wsMessage{Type: "subscribed", RequestID: req.RequestID, ClientIDs: c.subscribedIDs()})
+
+	case "unsubscribe":
+		c.mu.Lock()
+		for _, id := range req.ClientIDs {
+			if id == "*" {
+				c.all = false
+				c.subscriptions = make(map[string]bool)
+				continue
+			}
+			delete(c.subscriptions, id)
+		}
+		c.mu.Unlock()
+		c.reply(wsMessage{Type: "subscribed", RequestID: req.RequestID, ClientIDs: c.subscribedIDs()})
+
+	case "history":
+		if req.ClientID == "" {
+			c.replyError(req, "client_id requis")
+			return
+		}
+		options, err := c.historyOptions(req)
+		if err != nil {
+			c.replyError(req, err.Error())
+			return
+		}
+		history, err := c.s.getFilteredClientHistory(options)
+		if err != nil {
+			log.Printf("Erreur de récupération de l'historique (WebSocket) pour le client %s: %v", req.ClientID, err)
+			c.replyError(req, "Erreur de récupération de l'historique")
+			return
+		}
+		c.reply(wsMessage{Type: "history", RequestID: req.RequestID, ClientID: req.ClientID, Data: history})
+
+	case "anomalies":
+		if req.ClientID == "" {
+			c.replyError(req, "client_id requis")
+			return
+		}
+		options, err := c.historyOptions(req)
+		if err != nil {
+			c.replyError(req, err.Error())
+			return
+		}
+		// Without an explicit threshold, use the client's anomaly detection
+		threshold := req.ThresholdMs
+		if threshold < 0 {
+			threshold = 0
+		}
+		anomalies, err := c.s.getAnomalies(c.tenantID, req.ClientID, threshold, options.Duration, options.Limit)
+		if err != nil {
+			log.Printf("Erreur de récupération des anomalies (WebSocket) pour le client %s: %v", req.ClientID, err)
+			c.replyError(req, "Erreur de récupération des anomalies")
+			return
+		}
+		c.reply(wsMessage{Type: "anomalies", RequestID: req.RequestID, ClientID: req.ClientID, Data: anomalies})
+
+	case "clients":
+		clients, err := c.s.getClientStatuses(c.tenantID)
+		if err != nil {
+			log.Printf("Erreur récupération données clients (WebSocket): %v", err)
+			c.replyError(req, "Erreur de récupération des données")
+			return
+		}
+		c.reply(wsMessage{Type: "clients", RequestID: req.RequestID, Data: clients})
+
+	case "dashboard":
+		options, err := c.historyOptions(req)
+		if err != nil {
+			c.replyError(req, err.Error())
+			return
+		}
+		dashboard, err := c.s.loadDashboardData(options)
+		if err != nil {
+			log.Printf("Erreur récupération données clients (WebSocket): %v", err)
+			c.replyError(req, "Erreur de récupération des données")
+			return
+		}
+		c.reply(wsMessage{Type: "dashboard", RequestID: req.RequestID, Data: dashboard})
+
+	case "ping":
+		c.reply(wsMessage{Type: "pong", RequestID: req.RequestID})
+
+	default:
+		c.replyError(req, "Type de requête inconnu: "+req.Type)
+	}
+}
+
+func (c *wsConn) replyError(req wsRequest, message string) {
+	c.reply(wsMessage{Type: "error", RequestID: req.RequestID, Error: message})
+}
+
+func (c *wsConn) subscribedIDs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.all {
+		return []string{"*"}
+	}
+	ids := make([]string, 0, len(c.subscriptions))
+	for id := range c.subscriptions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// historyOptions converts a query request into the filter options used by
+// the HTTP dashboard, with the same defaults and limit cap, scoped to the
+// peer's tenant.
+func (c *wsConn) historyOptions(req wsRequest) (HistoryFilterOptions, error) {
+	options := HistoryFilterOptions{
+		TenantID:     c.tenantID,
+		ClientID:     req.ClientID,
+		Duration:     1 * time.Hour,
+		SortBy:       req.SortBy,
+		SortOrder:    req.SortOrder,
+		Limit:        req.Limit,
+		StatusFilter: req.StatusFilter,
+		MinLatency:   req.MinLatency,
+		MaxLatency:   req.MaxLatency,
+	}
+
+	if req.Duration != "" {
+		duration, err := parseDuration(req.Duration)
+		if err != nil {
+			return options, err
+		}
+		options.Duration = duration
+	}
+	if options.SortBy == "" {
+		options.SortBy = "timestamp"
+	}
+	if options.SortOrder == "" {
+		options.SortOrder = "desc"
+	}
+	if options.Limit <= 0 {
+		options.Limit = 50
+	}
+	if options.Limit > maxHistoryLimit {
+		options.Limit = maxHistoryLimit
+	}
+	if options.StatusFilter == "" {
+		options.StatusFilter = "all"
+	}
+	return options, nil
+}
\ No newline at end of file